@@ -0,0 +1,165 @@
+package urlshort
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// WritableStore is a Store whose mappings can be changed.
+type WritableStore interface {
+	Store
+	Put(path, url string) error
+	Delete(path string) error
+}
+
+// InsertStore is a WritableStore that can atomically store a
+// mapping only if its path has none yet, so concurrent writers
+// cannot overwrite each other.
+type InsertStore interface {
+	WritableStore
+	// Insert stores a redirect from path to url unless path
+	// already has one, and reports whether it did.
+	Insert(path, url string) (inserted bool, err error)
+}
+
+// insert stores a redirect from path to url in store unless path
+// already has one, and reports whether it did. Only an
+// InsertStore does so atomically.
+func insert(store WritableStore, path, url string) (bool, error) {
+	if is, ok := store.(InsertStore); ok {
+		return is.Insert(path, url)
+	}
+	_, exists, err := peek(store, path)
+	if err != nil || exists {
+		return false, err
+	}
+	return true, store.Put(path, url)
+}
+
+// ListableStore is a Store whose mappings can be enumerated.
+type ListableStore interface {
+	Store
+	Each(fn func(path, url string) error) error
+}
+
+// AdminHandler will return an http.Handler exposing a JSON API to
+// manage the mappings of store:
+//
+//     GET    /admin/links           list every mapping
+//     POST   /admin/links           create a mapping from a {"path": ..., "url": ...} body
+//     DELETE /admin/links?path=/x   delete the mapping for /x
+//
+// Creating a path that already exists responds with 409 and
+// deleting an unknown path with 404. The url of a new mapping
+// must be an absolute URL. Listing requires store to be a
+// ListableStore and changes require a WritableStore; otherwise
+// the request is answered with 501.
+//
+// The handler performs no authentication. Callers exposing it
+// should wrap it in whatever access control they need.
+func AdminHandler(store Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/admin/links" {
+			http.NotFound(w, r)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			adminList(w, store)
+		case http.MethodPost:
+			adminCreate(w, r, store)
+		case http.MethodDelete:
+			adminDelete(w, r, store)
+		default:
+			w.Header().Set("Allow", "GET, POST, DELETE")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+func adminList(w http.ResponseWriter, store Store) {
+	ls, ok := store.(ListableStore)
+	if !ok {
+		http.Error(w, errNotListable.Error(), http.StatusNotImplemented)
+		return
+	}
+	links := []entry{}
+	err := ls.Each(func(path, url string) error {
+		links = append(links, entry{Path: path, URL: url})
+		return nil
+	})
+	if err == errNotListable {
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, links)
+}
+
+func adminCreate(w http.ResponseWriter, r *http.Request, store Store) {
+	ws, ok := store.(WritableStore)
+	if !ok {
+		http.Error(w, errNotWritable.Error(), http.StatusNotImplemented)
+		return
+	}
+	var link entry
+	if err := json.NewDecoder(r.Body).Decode(&link); err != nil {
+		http.Error(w, "invalid body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if link.Path == "" {
+		http.Error(w, "path is required", http.StatusBadRequest)
+		return
+	}
+	if err := checkAbsoluteURL(link.URL); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	inserted, err := insert(ws, link.Path, link.URL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !inserted {
+		http.Error(w, link.Path+" already exists", http.StatusConflict)
+		return
+	}
+	writeJSON(w, http.StatusCreated, entry{Path: link.Path, URL: link.URL})
+}
+
+func adminDelete(w http.ResponseWriter, r *http.Request, store Store) {
+	ws, ok := store.(WritableStore)
+	if !ok {
+		http.Error(w, errNotWritable.Error(), http.StatusNotImplemented)
+		return
+	}
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		http.Error(w, "path is required", http.StatusBadRequest)
+		return
+	}
+	_, exists, err := peek(ws, path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, path+" not found", http.StatusNotFound)
+		return
+	}
+	if err := ws.Delete(path); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeJSON writes v as the JSON body of a response with status.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,87 @@
+package urlshort
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// adminRequest sends a request with body to h and returns the
+// recorded response.
+func adminRequest(h http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return w
+}
+
+func TestAdminHandler(t *testing.T) {
+	h := AdminHandler(openTestBolt(t, BoltOptions{}))
+	expect := func(method, target, body string, status int) *httptest.ResponseRecorder {
+		t.Helper()
+		w := adminRequest(h, method, target, body)
+		if w.Code != status {
+			t.Errorf("%s %s %s = %d, want %d", method, target, body, w.Code, status)
+		}
+		return w
+	}
+	expect(http.MethodPost, "/admin/links", `{"path": "/x", "url": "https://x.com"}`, http.StatusCreated)
+	expect(http.MethodPost, "/admin/links", `{"path": "/x", "url": "https://y.com"}`, http.StatusConflict)
+	expect(http.MethodPost, "/admin/links", `{"path": "/y", "url": "nope"}`, http.StatusBadRequest)
+	expect(http.MethodPost, "/admin/links", `{`, http.StatusBadRequest)
+
+	w := expect(http.MethodGet, "/admin/links", "", http.StatusOK)
+	if got := strings.TrimSpace(w.Body.String()); got != `[{"path":"/x","url":"https://x.com"}]` {
+		t.Errorf("GET /admin/links = %s", got)
+	}
+
+	expect(http.MethodDelete, "/admin/links?path=/x", "", http.StatusNoContent)
+	expect(http.MethodDelete, "/admin/links?path=/x", "", http.StatusNotFound)
+	expect(http.MethodPut, "/admin/links", "", http.StatusMethodNotAllowed)
+}
+
+func TestAdminHandlerReadOnlyStore(t *testing.T) {
+	h := AdminHandler(MapStore{})
+	if w := adminRequest(h, http.MethodPost, "/admin/links", `{"path": "/x", "url": "https://x.com"}`); w.Code != http.StatusNotImplemented {
+		t.Errorf("POST to a read-only store = %d, want 501", w.Code)
+	}
+}
+
+func TestAdminCreateRace(t *testing.T) {
+	h := AdminHandler(openTestBolt(t, BoltOptions{}))
+	var wg sync.WaitGroup
+	var created int32
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			body := fmt.Sprintf(`{"path": "/r", "url": "https://%d.com"}`, i)
+			switch w := adminRequest(h, http.MethodPost, "/admin/links", body); w.Code {
+			case http.StatusCreated:
+				atomic.AddInt32(&created, 1)
+			case http.StatusConflict:
+			default:
+				t.Errorf("POST = %d, want 201 or 409", w.Code)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if created != 1 {
+		t.Errorf("%d concurrent creates succeeded, want 1", created)
+	}
+}
+
+func TestAdminDeleteDoesNotConsume(t *testing.T) {
+	s := openTestBolt(t, BoltOptions{})
+	s.PutSingleUse("/once", "https://o.com")
+	h := AdminHandler(s)
+	if w := adminRequest(h, http.MethodDelete, "/admin/links?path=/nothing", ""); w.Code != http.StatusNotFound {
+		t.Errorf("DELETE of a missing path = %d, want 404", w.Code)
+	}
+	if _, ok, _ := s.Peek("/once"); !ok {
+		t.Error("single-use link was consumed")
+	}
+}
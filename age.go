@@ -0,0 +1,45 @@
+package urlshort
+
+import (
+	"sort"
+	"time"
+)
+
+// CreatedStore is implemented by stores that record when each of
+// their redirects was created, such as a BoltStore or a SQLStore
+// with creation queries.
+type CreatedStore interface {
+	// Created returns the creation time of the redirect for path.
+	// ok is false if path has no redirect. A redirect stored
+	// before its store recorded creation times has a zero time.
+	Created(path string) (created time.Time, ok bool, err error)
+
+	// EachCreated calls fn with the creation time of every
+	// redirect, zero if unknown, stopping at the first error fn
+	// returns.
+	EachCreated(fn func(path string, created time.Time) error) error
+}
+
+// CreatedBefore returns the redirects of store created before
+// cutoff, oldest first, with their Created time set. Redirects
+// whose creation time is unknown are left out, since their age
+// cannot be told.
+func CreatedBefore(store CreatedStore, cutoff time.Time) ([]LinkStat, error) {
+	var stats []LinkStat
+	err := store.EachCreated(func(path string, created time.Time) error {
+		if !created.IsZero() && created.Before(cutoff) {
+			stats = append(stats, LinkStat{Path: path, Created: &created})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if !stats[i].Created.Equal(*stats[j].Created) {
+			return stats[i].Created.Before(*stats[j].Created)
+		}
+		return stats[i].Path < stats[j].Path
+	})
+	return stats, nil
+}
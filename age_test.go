@@ -0,0 +1,55 @@
+package urlshort
+
+import (
+	"testing"
+	"time"
+)
+
+// createdMap is a CreatedStore holding creation times by path.
+type createdMap map[string]time.Time
+
+func (m createdMap) Created(path string) (time.Time, bool, error) {
+	c, ok := m[path]
+	return c, ok, nil
+}
+
+func (m createdMap) EachCreated(fn func(string, time.Time) error) error {
+	for p, c := range m {
+		if err := fn(p, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestCreatedBefore(t *testing.T) {
+	day := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := createdMap{
+		"/c":       day.Add(2 * time.Hour),
+		"/a":       day,
+		"/b":       day,
+		"/new":     day.Add(48 * time.Hour),
+		"/unknown": {},
+	}
+	got, err := CreatedBefore(store, day.Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, s := range got {
+		paths = append(paths, s.Path)
+	}
+	if len(paths) != 3 || paths[0] != "/a" || paths[1] != "/b" || paths[2] != "/c" {
+		t.Errorf("CreatedBefore = %v, want /a /b /c", paths)
+	}
+
+	s := openTestBolt(t, BoltOptions{})
+	s.Put("/a", "https://a.com")
+	old, err := CreatedBefore(s, time.Now().Add(time.Hour))
+	if err != nil || len(old) != 1 || old[0].Path != "/a" {
+		t.Errorf("CreatedBefore(an hour from now) = %v, %v, want /a", old, err)
+	}
+	if old, _ := CreatedBefore(s, time.Now().Add(-time.Hour)); len(old) != 0 {
+		t.Errorf("CreatedBefore(an hour ago) = %v, want none", old)
+	}
+}
@@ -0,0 +1,63 @@
+package urlshort
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/boltdb/bolt"
+)
+
+// BoltBackup writes a snapshot of the whole database to w. The
+// snapshot is taken inside a read transaction, so it is
+// consistent even while the database is being written to, and is
+// itself a BoltDB file that can be opened in place of the
+// original.
+func BoltBackup(db *bolt.DB, w io.Writer) error {
+	return db.View(func(tx *bolt.Tx) error {
+		_, err := tx.WriteTo(w)
+		return err
+	})
+}
+
+// Backup writes a snapshot of the database of s to w. See
+// BoltBackup.
+func (s *BoltStore) Backup(w io.Writer) error {
+	return BoltBackup(s.db, w)
+}
+
+// BoltRestore creates the named bucket in db, or DefaultBoltBucket
+// if bucket is empty, and fills it with the mappings of a document
+// in the given format, such as one written by ExportJSON or
+// ExportYAML. It is meant for restoring into a fresh database:
+// an error is returned if the bucket already exists. The document
+// is checked as by Import, and the mappings are written in a
+// single transaction, so either all of them are restored or none.
+func BoltRestore(db *bolt.DB, bucket string, data []byte, format Format) error {
+	if bucket == "" {
+		bucket = DefaultBoltBucket
+	}
+	entries, err := decodeEntries(data, format)
+	if err != nil {
+		return err
+	}
+	if err := checkTargets(entries); err != nil {
+		return err
+	}
+	paths, err := buildRedirectMapStrict(entries)
+	if err != nil {
+		return err
+	}
+
+	return db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte(bucket))
+		if err != nil {
+			return fmt.Errorf("create bucket: %s", err)
+		}
+		for _, e := range mapEntries(paths) {
+			if err := b.Put([]byte(e.Path), []byte(e.URL)); err != nil {
+				return fmt.Errorf("put: %s", err)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,74 @@
+package urlshort
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/boltdb/bolt"
+)
+
+func TestBoltStoreBackup(t *testing.T) {
+	dir := t.TempDir()
+	s, err := OpenBoltStore(filepath.Join(dir, "a.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.Put("/a", "https://a.com")
+	s.Put("/b", "https://b.com")
+
+	var buf bytes.Buffer
+	if err := s.Backup(&buf); err != nil {
+		t.Fatal(err)
+	}
+	copyFile := filepath.Join(dir, "copy.db")
+	if err := os.WriteFile(copyFile, buf.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	c, err := OpenBoltStore(copyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if u, ok, _ := c.Lookup("/b"); !ok || u != "https://b.com" {
+		t.Errorf("backup Lookup(/b) = %q, %v, want https://b.com", u, ok)
+	}
+}
+
+func TestBoltRestore(t *testing.T) {
+	doc, err := ExportJSON(map[string]string{"/a": "https://a.com", "/b": "https://b.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := filepath.Join(t.TempDir(), "r.db")
+	db, err := bolt.Open(f, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := BoltRestore(db, "", doc, FormatJSON); err != nil {
+		t.Fatal(err)
+	}
+	if err := BoltRestore(db, "", doc, FormatJSON); err == nil {
+		t.Error("expected an error restoring into an existing bucket")
+	}
+	if err := BoltRestore(db, "Bad", []byte(`{"/c": "relative"}`), FormatJSON); err == nil {
+		t.Error("expected an error restoring an invalid target")
+	}
+	db.Close()
+
+	s, err := OpenBoltStore(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	got := map[string]string{}
+	s.Each(func(path, url string) error {
+		got[path] = url
+		return nil
+	})
+	if len(got) != 2 || got["/a"] != "https://a.com" || got["/b"] != "https://b.com" {
+		t.Errorf("restored %v", got)
+	}
+}
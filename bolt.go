@@ -0,0 +1,591 @@
+package urlshort
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+	"unicode"
+
+	"github.com/boltdb/bolt"
+)
+
+// BoltStore is a Store backed by a bucket in a BoltDB file.
+// Lookups read from the bucket on every call, so changes made
+// to the database are picked up without rebuilding the handler.
+// The database stays open until Close is called.
+type BoltStore struct {
+	db            *bolt.DB
+	bucket        []byte
+	hitsBucket    []byte
+	accessBucket  []byte
+	createdBucket []byte
+	onceBucket    []byte
+	countHits     bool
+	clock         Clock
+}
+
+// DefaultBoltBucket is the bucket redirects are stored in when
+// no other bucket name is configured.
+const DefaultBoltBucket = "URLRedirects"
+
+// DefaultBoltTimeout is how long OpenBoltStore and the Bolt
+// handlers wait for another process to release the database file.
+const DefaultBoltTimeout = 10 * time.Second
+
+// BoltOptions configures a BoltStore.
+type BoltOptions struct {
+	// Bucket is the name of the bucket holding the redirects.
+	// Empty means DefaultBoltBucket. The bucket is created if it
+	// does not exist; other buckets in the file are left alone.
+	Bucket string
+
+	// Seed stores a demo redirect for /urlshort-bolt when the
+	// bucket is first created. It is meant for examples only and
+	// is off unless explicitly requested.
+	Seed bool
+
+	// CountHits records how many times each path has been
+	// successfully looked up, and when it last was. See
+	// BoltStore.Hits and BoltStore.Stats.
+	CountHits bool
+
+	// Timeout is how long to wait for another process holding the
+	// database file open to release it. As with bolt.Open, zero
+	// waits indefinitely; use DefaultBoltTimeout for the timeout
+	// of OpenBoltStore.
+	Timeout time.Duration
+
+	// Retries is how many more times to try opening the database
+	// file when it is still held by another process once Timeout
+	// has passed, for instance while an older instance shuts down
+	// during a rolling restart. Other errors are not retried.
+	Retries int
+
+	// RetryBackoff is how long to wait before the first retry. The
+	// wait doubles after every retry. Zero means
+	// DefaultBoltRetryBackoff.
+	RetryBackoff time.Duration
+
+	// Clock tells the time recorded as the creation time of new
+	// redirects and the last access of looked up ones. Nil means
+	// SystemClock.
+	Clock Clock
+}
+
+// DefaultBoltRetryBackoff is the wait before the first retry of
+// opening a locked database file when BoltOptions.RetryBackoff is
+// not set.
+const DefaultBoltRetryBackoff = 100 * time.Millisecond
+
+// OpenBoltStore opens the BoltDB file at boltFile, creating it
+// and the redirect bucket if they do not exist yet.
+func OpenBoltStore(boltFile string) (*BoltStore, error) {
+	return OpenBoltStoreWithOptions(boltFile, BoltOptions{Timeout: DefaultBoltTimeout})
+}
+
+// OpenBoltStoreWithOptions behaves like OpenBoltStore but
+// configures the store as described by opts.
+func OpenBoltStoreWithOptions(boltFile string, opts BoltOptions) (*BoltStore, error) {
+	if opts.Bucket == "" {
+		opts.Bucket = DefaultBoltBucket
+	}
+
+	db, err := openBolt(boltFile, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// This bit of code is to be run if the Bolt file does not exist.
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, err2 := tx.CreateBucket([]byte(opts.Bucket))
+		if err2 == bolt.ErrBucketExists {
+			return nil
+		}
+		if err2 != nil {
+			return fmt.Errorf("create bucket: %s", err2)
+		}
+		if !opts.Seed {
+			return nil
+		}
+		err2 = b.Put([]byte("/urlshort-bolt"), []byte("https://github.com/bcpoole/urlshort"))
+		if err2 != nil {
+			return fmt.Errorf("put: %s", err2)
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	clock := opts.Clock
+	if clock == nil {
+		clock = SystemClock
+	}
+	return newBoltStore(db, opts.Bucket, opts.CountHits, clock), nil
+}
+
+// openBolt opens the database file, retrying as configured by
+// opts while another process holds it. The error of the last
+// attempt is returned.
+func openBolt(boltFile string, opts BoltOptions) (*bolt.DB, error) {
+	backoff := opts.RetryBackoff
+	if backoff <= 0 {
+		backoff = DefaultBoltRetryBackoff
+	}
+	for attempt := 0; ; attempt++ {
+		db, err := bolt.Open(boltFile, 0600, &bolt.Options{Timeout: opts.Timeout})
+		if err != bolt.ErrTimeout || attempt >= opts.Retries {
+			return db, err
+		}
+		log.Printf("urlshort: %s is locked, retrying in %s", boltFile, backoff)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// newBoltStore returns a BoltStore keeping its redirects in bucket
+// and its other data in the buckets named after it, recording
+// times from clock.
+func newBoltStore(db *bolt.DB, bucket string, countHits bool, clock Clock) *BoltStore {
+	return &BoltStore{
+		db:            db,
+		bucket:        []byte(bucket),
+		hitsBucket:    []byte(bucket + ".hits"),
+		accessBucket:  []byte(bucket + ".access"),
+		createdBucket: []byte(bucket + ".created"),
+		onceBucket:    []byte(bucket + ".once"),
+		countHits:     countHits,
+		clock:         clock,
+	}
+}
+
+// Lookup returns the url stored for path in the redirect bucket.
+// When hit counting is enabled, a successful lookup also
+// increments the counter for path and records the time of the
+// lookup in the same transaction.
+// Paths not in the redirect bucket may still be single-use
+// redirects, which are consumed by the lookup; see PutSingleUse.
+// Use Peek to check for a path without these side effects.
+func (s *BoltStore) Lookup(path string) (string, bool, error) {
+	var url string
+	var ok bool
+	lookup := func(tx *bolt.Tx) error {
+		b := tx.Bucket(s.bucket)
+		if b == nil {
+			return nil
+		}
+		v := b.Get([]byte(path))
+		if v != nil {
+			url, ok = string(v), true
+		}
+		return nil
+	}
+	if !s.countHits {
+		if err := s.db.View(lookup); err != nil || ok {
+			return url, ok, err
+		}
+		return s.consume(path)
+	}
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		if err := lookup(tx); err != nil || !ok {
+			return err
+		}
+		hits, err := tx.CreateBucketIfNotExists(s.hitsBucket)
+		if err != nil {
+			return fmt.Errorf("create bucket: %s", err)
+		}
+		count := make([]byte, 8)
+		binary.BigEndian.PutUint64(count, decodeHits(hits.Get([]byte(path)))+1)
+		if err := hits.Put([]byte(path), count); err != nil {
+			return err
+		}
+		access, err := tx.CreateBucketIfNotExists(s.accessBucket)
+		if err != nil {
+			return fmt.Errorf("create bucket: %s", err)
+		}
+		return access.Put([]byte(path), encodeTime(s.clock.Now()))
+	})
+	if err != nil || ok {
+		return url, ok, err
+	}
+	return s.consume(path)
+}
+
+// Peek returns the url stored for path, including a single-use
+// one, without consuming it or counting a hit.
+func (s *BoltStore) Peek(path string) (string, bool, error) {
+	return s.get(path, s.bucket, s.onceBucket)
+}
+
+// Reveal returns the url stored for path without counting a hit,
+// reporting single-use redirects as missing.
+func (s *BoltStore) Reveal(path string) (string, bool, error) {
+	return s.get(path, s.bucket)
+}
+
+// get returns the url stored for path in the first of buckets
+// that has one.
+func (s *BoltStore) get(path string, buckets ...[]byte) (string, bool, error) {
+	var url string
+	var ok bool
+	err := s.db.View(func(tx *bolt.Tx) error {
+		for _, name := range buckets {
+			if b := tx.Bucket(name); b != nil {
+				if v := b.Get([]byte(path)); v != nil {
+					url, ok = string(v), true
+					return nil
+				}
+			}
+		}
+		return nil
+	})
+	return url, ok, err
+}
+
+// consume looks up the single-use redirect for path and deletes
+// it in the same transaction, so that only one lookup can ever
+// return it.
+func (s *BoltStore) consume(path string) (string, bool, error) {
+	// Check for the path in a read-only transaction first, as
+	// most paths are not single-use and a write transaction must
+	// sync the file.
+	var pending bool
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket(s.onceBucket); b != nil {
+			pending = b.Get([]byte(path)) != nil
+		}
+		return nil
+	})
+	if err != nil || !pending {
+		return "", false, err
+	}
+
+	var url string
+	var ok bool
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(s.onceBucket)
+		if b == nil {
+			return nil
+		}
+		v := b.Get([]byte(path))
+		if v == nil {
+			return nil
+		}
+		url, ok = string(v), true
+		return b.Delete([]byte(path))
+	})
+	if err != nil {
+		return "", false, err
+	}
+	return url, ok, nil
+}
+
+// PutSingleUse stores a redirect from path to url that is served
+// only once: the first lookup of path deletes it. Single-use
+// redirects are kept apart from the others, which take precedence
+// for the same path, and are not listed by Each. url must be an
+// absolute URL.
+func (s *BoltStore) PutSingleUse(path, url string) error {
+	if err := checkAbsoluteURL(url); err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(s.onceBucket)
+		if err != nil {
+			return fmt.Errorf("create bucket: %s", err)
+		}
+		return b.Put([]byte(path), []byte(url))
+	})
+}
+
+// Put stores a redirect from path to url, replacing any existing
+// mapping for path. url must be an absolute URL. The time a path
+// is first stored is recorded as its creation time; replacing its
+// url keeps it.
+func (s *BoltStore) Put(path, url string) error {
+	_, err := s.put(path, url, true)
+	return err
+}
+
+// Insert stores a redirect from path to url in the same
+// transaction that checks that path has none, including a
+// single-use one, and reports whether it did. url must be an
+// absolute URL.
+func (s *BoltStore) Insert(path, url string) (bool, error) {
+	return s.put(path, url, false)
+}
+
+// put stores a redirect from path to url, unless path already has
+// one and replace is false, and reports whether it did.
+func (s *BoltStore) put(path, url string, replace bool) (bool, error) {
+	if err := checkAbsoluteURL(url); err != nil {
+		return false, err
+	}
+	var stored bool
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(s.bucket)
+		if err != nil {
+			return fmt.Errorf("create bucket: %s", err)
+		}
+		exists := b.Get([]byte(path)) != nil
+		if !replace {
+			if once := tx.Bucket(s.onceBucket); exists || once != nil && once.Get([]byte(path)) != nil {
+				return nil
+			}
+		}
+		created, err := tx.CreateBucketIfNotExists(s.createdBucket)
+		if err != nil {
+			return fmt.Errorf("create bucket: %s", err)
+		}
+		if !exists {
+			if err := created.Put([]byte(path), encodeTime(s.clock.Now())); err != nil {
+				return err
+			}
+		}
+		stored = true
+		return b.Put([]byte(path), []byte(url))
+	})
+	return stored && err == nil, err
+}
+
+// Delete removes the redirect for path, including a single-use
+// one, along with its creation time. Deleting a path that has no
+// mapping is not an error.
+func (s *BoltStore) Delete(path string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{s.bucket, s.onceBucket, s.createdBucket} {
+			if b := tx.Bucket(name); b != nil {
+				if err := b.Delete([]byte(path)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// Each calls fn for every redirect in the bucket, in path order,
+// stopping at the first error fn returns.
+func (s *BoltStore) Each(fn func(path, url string) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(s.bucket)
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			return fn(string(k), string(v))
+		})
+	})
+}
+
+// Ping checks that the database is open and its redirect bucket
+// can be read.
+func (s *BoltStore) Ping(ctx context.Context) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(s.bucket) == nil {
+			return fmt.Errorf("bucket %s not found", s.bucket)
+		}
+		return nil
+	})
+}
+
+// Hits returns the number of successful lookups of path recorded
+// while hit counting was enabled.
+func (s *BoltStore) Hits(path string) (uint64, error) {
+	var count uint64
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket(s.hitsBucket); b != nil {
+			count = decodeHits(b.Get([]byte(path)))
+		}
+		return nil
+	})
+	return count, err
+}
+
+// EachHit calls fn with the hit count of every path looked up
+// while hit counting was enabled, in path order, stopping at the
+// first error fn returns.
+func (s *BoltStore) EachHit(fn func(path string, hits uint64) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(s.hitsBucket)
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			return fn(string(k), decodeHits(v))
+		})
+	})
+}
+
+// Stats returns the statistics of the redirect for path. Hits
+// and LastAccess are only recorded while hit counting is enabled,
+// and Created only for paths stored by Put. ok is false if path
+// has no redirect.
+func (s *BoltStore) Stats(path string) (stat LinkStat, ok bool, err error) {
+	stat.Path = path
+	err = s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(s.bucket)
+		if b == nil || b.Get([]byte(path)) == nil {
+			return nil
+		}
+		ok = true
+		if b := tx.Bucket(s.hitsBucket); b != nil {
+			stat.Hits = decodeHits(b.Get([]byte(path)))
+		}
+		if b := tx.Bucket(s.accessBucket); b != nil {
+			stat.LastAccess = decodeTime(b.Get([]byte(path)))
+		}
+		if b := tx.Bucket(s.createdBucket); b != nil {
+			stat.Created = decodeTime(b.Get([]byte(path)))
+		}
+		return nil
+	})
+	return stat, ok, err
+}
+
+// Created returns the time path was first stored by Put. ok is
+// false if path has no redirect; redirects stored otherwise, or
+// before creation times were recorded, have a zero time.
+func (s *BoltStore) Created(path string) (created time.Time, ok bool, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(s.bucket)
+		if b == nil || b.Get([]byte(path)) == nil {
+			return nil
+		}
+		ok = true
+		if b := tx.Bucket(s.createdBucket); b != nil {
+			if t := decodeTime(b.Get([]byte(path))); t != nil {
+				created = *t
+			}
+		}
+		return nil
+	})
+	return created, ok, err
+}
+
+// EachCreated calls fn with the creation time of every redirect
+// in the bucket, zero if unknown, in path order, stopping at the
+// first error fn returns.
+func (s *BoltStore) EachCreated(fn func(path string, created time.Time) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(s.bucket)
+		if b == nil {
+			return nil
+		}
+		times := tx.Bucket(s.createdBucket)
+		return b.ForEach(func(k, _ []byte) error {
+			var created time.Time
+			if times != nil {
+				if t := decodeTime(times.Get(k)); t != nil {
+					created = *t
+				}
+			}
+			return fn(string(k), created)
+		})
+	})
+}
+
+// decodeHits decodes a counter stored in the hits bucket. A
+// missing counter decodes to zero.
+func decodeHits(v []byte) uint64 {
+	if len(v) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(v)
+}
+
+// encodeTime encodes t for storing in a bucket.
+func encodeTime(t time.Time) []byte {
+	v := make([]byte, 8)
+	binary.BigEndian.PutUint64(v, uint64(t.UnixNano()))
+	return v
+}
+
+// decodeTime decodes a time stored by encodeTime. A missing time
+// decodes to nil.
+func decodeTime(v []byte) *time.Time {
+	if len(v) != 8 {
+		return nil
+	}
+	t := time.Unix(0, int64(binary.BigEndian.Uint64(v)))
+	return &t
+}
+
+// Tenant returns a BoltStore for the redirects of the named
+// tenant, which live in their own bucket of the same file. The
+// bucket is created by the first Put. The returned store shares
+// the database of s, so only one of them should be closed.
+//
+// An error is returned if name is empty or contains a dot, a
+// slash or a control character, which could otherwise name the
+// bucket of another tenant or the hit counters of a store.
+func (s *BoltStore) Tenant(name string) (*BoltStore, error) {
+	if err := checkTenantName(name); err != nil {
+		return nil, err
+	}
+	return newBoltStore(s.db, string(s.bucket)+"/"+name, s.countHits, s.clock), nil
+}
+
+// checkTenantName reports an error if name cannot name a tenant.
+func checkTenantName(name string) error {
+	if name == "" {
+		return fmt.Errorf("tenant name is empty")
+	}
+	for _, r := range name {
+		if r == '.' || r == '/' || unicode.IsControl(r) {
+			return fmt.Errorf("tenant name %q contains %q", name, r)
+		}
+	}
+	return nil
+}
+
+// Close closes the underlying BoltDB file.
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+// BoltHandler reads a BoltDB of url handler mappings an redirects base on those inputs.
+// Else falls back to provided Handler. The returned io.Closer must be closed once the
+// handler is no longer in use.
+func BoltHandler(boltFile string, fallback http.Handler) (http.HandlerFunc, io.Closer, error) {
+	return BoltHandlerWithStatus(boltFile, fallback, http.StatusFound)
+}
+
+// BoltHandlerWithStatus behaves like BoltHandler but redirects using the provided status code.
+func BoltHandlerWithStatus(boltFile string, fallback http.Handler, status int) (http.HandlerFunc, io.Closer, error) {
+	opts, err := statusOptions(status)
+	if err != nil {
+		return nil, nil, err
+	}
+	return BoltHandlerWithOptions(boltFile, fallback, opts)
+}
+
+// BoltHandlerWithOptions behaves like BoltHandler but responds to matched paths as configured by opts.
+func BoltHandlerWithOptions(boltFile string, fallback http.Handler, opts Options) (http.HandlerFunc, io.Closer, error) {
+	return boltHandler(boltFile, BoltOptions{Timeout: DefaultBoltTimeout}, fallback, opts)
+}
+
+// BoltHandlerWithBucket behaves like BoltHandler but reads the redirects from the named
+// bucket, which is created empty if it does not exist.
+func BoltHandlerWithBucket(boltFile, bucket string, fallback http.Handler) (http.HandlerFunc, io.Closer, error) {
+	return boltHandler(boltFile, BoltOptions{Bucket: bucket, Timeout: DefaultBoltTimeout}, fallback, Options{})
+}
+
+func boltHandler(boltFile string, boltOpts BoltOptions, fallback http.Handler, opts Options) (http.HandlerFunc, io.Closer, error) {
+	if _, err := opts.withDefaults(); err != nil {
+		return nil, nil, err
+	}
+
+	store, err := OpenBoltStoreWithOptions(boltFile, boltOpts)
+	if err != nil {
+		return nil, nil, err
+	}
+	handler, _ := StoreHandlerWithOptions(store, fallback, opts)
+	return handler, store, nil
+}
@@ -0,0 +1,281 @@
+package urlshort
+
+import (
+	"net/http"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// openTestBolt opens a BoltStore in a fresh temporary file and closes
+// it when the test ends.
+func openTestBolt(t *testing.T, opts BoltOptions) *BoltStore {
+	t.Helper()
+	s, err := OpenBoltStoreWithOptions(filepath.Join(t.TempDir(), "test.db"), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestBoltHandler(t *testing.T) {
+	h, c, err := BoltHandler(filepath.Join(t.TempDir(), "b.db"), notFound)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	expectStatus(t, h, "/urlshort-bolt", http.StatusNotFound)
+
+	// Writes made to the database after the handler was built are
+	// served without rebuilding it.
+	err = c.(*BoltStore).db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(DefaultBoltBucket)).Put([]byte("/n"), []byte("https://n.com"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectRedirect(t, h, "/n", http.StatusFound, "https://n.com")
+}
+
+func TestBoltHandlerOpenError(t *testing.T) {
+	h, c, err := BoltHandler(filepath.Join(t.TempDir(), "missing", "b.db"), notFound)
+	if err == nil || h != nil || c != nil {
+		t.Fatalf("BoltHandler = %v, %v, %v, want only an error", h, c, err)
+	}
+}
+
+func TestBoltHandlerReopen(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "b.db")
+	for i := 0; i < 2; i++ {
+		_, c, err := BoltHandler(f, notFound)
+		if err != nil {
+			t.Fatalf("open %d: %v", i, err)
+		}
+		if err := c.Close(); err != nil {
+			t.Fatalf("close %d: %v", i, err)
+		}
+	}
+}
+
+func TestBoltHandlerWithBucket(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "b.db")
+	s, err := OpenBoltStoreWithOptions(f, BoltOptions{Seed: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	h, c, err := BoltHandlerWithBucket(f, "Other", notFound)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, h, "/urlshort-bolt", http.StatusNotFound)
+	if err := c.(*BoltStore).Put("/o", "https://o.com"); err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, h, "/o", http.StatusFound)
+	c.Close()
+
+	h, c, err = BoltHandler(f, notFound)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	expectStatus(t, h, "/o", http.StatusNotFound)
+	expectStatus(t, h, "/urlshort-bolt", http.StatusFound)
+}
+
+func TestBoltStorePutDelete(t *testing.T) {
+	s := openTestBolt(t, BoltOptions{})
+	h := StoreHandler(s, notFound)
+	if err := s.Put("/p", "https://p.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put("/q", "/relative"); err == nil {
+		t.Error("expected an error for a relative URL")
+	}
+	expectRedirect(t, h, "/p", http.StatusFound, "https://p.com")
+	if err := s.Delete("/p"); err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, h, "/p", http.StatusNotFound)
+}
+
+func TestBoltStoreInsert(t *testing.T) {
+	s := openTestBolt(t, BoltOptions{})
+	if ok, err := s.Insert("/a", "https://a.com"); !ok || err != nil {
+		t.Fatalf("first Insert = %v, %v, want true", ok, err)
+	}
+	if ok, err := s.Insert("/a", "https://b.com"); ok || err != nil {
+		t.Fatalf("second Insert = %v, %v, want false", ok, err)
+	}
+	if u, _, _ := s.Peek("/a"); u != "https://a.com" {
+		t.Errorf("Insert replaced the target with %q", u)
+	}
+
+	if err := s.PutSingleUse("/once", "https://o.com"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := s.Insert("/once", "https://x.com"); ok {
+		t.Error("Insert took a path held by a single-use link")
+	}
+}
+
+func TestBoltStoreHits(t *testing.T) {
+	s := openTestBolt(t, BoltOptions{Seed: true, CountHits: true})
+	h := StoreHandler(s, notFound)
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			serve(h, http.MethodGet, "/urlshort-bolt")
+		}()
+	}
+	wg.Wait()
+	serve(h, http.MethodGet, "/miss")
+
+	if n, err := s.Hits("/urlshort-bolt"); err != nil || n != 50 {
+		t.Errorf("Hits(/urlshort-bolt) = %d, %v, want 50", n, err)
+	}
+	if n, _ := s.Hits("/miss"); n != 0 {
+		t.Errorf("Hits(/miss) = %d, want 0", n)
+	}
+}
+
+func TestBoltSingleUse(t *testing.T) {
+	s := openTestBolt(t, BoltOptions{})
+	h := StoreHandler(s, notFound)
+	for i := 0; i < 20; i++ {
+		if err := s.PutSingleUse("/secret", "https://s.com"); err != nil {
+			t.Fatal(err)
+		}
+		var wg sync.WaitGroup
+		codes := make([]int, 2)
+		for j := range codes {
+			wg.Add(1)
+			go func(j int) {
+				defer wg.Done()
+				codes[j] = serve(h, http.MethodGet, "/secret").Code
+			}(j)
+		}
+		wg.Wait()
+		if codes[0]+codes[1] != http.StatusFound+http.StatusNotFound {
+			t.Fatalf("concurrent requests got %v, want exactly one redirect", codes)
+		}
+	}
+
+	s.PutSingleUse("/x", "https://x.com")
+	s.Delete("/x")
+	expectStatus(t, h, "/x", http.StatusNotFound)
+}
+
+func TestBoltPeek(t *testing.T) {
+	s := openTestBolt(t, BoltOptions{CountHits: true})
+	s.PutSingleUse("/once", "https://o.com")
+	s.Put("/a", "https://a.com")
+	h := StoreHandler(s, notFound)
+
+	// HEAD requests, Peek and Reveal neither consume single-use
+	// links nor count as hits, and HEAD requests do not give the
+	// target of a single-use link away.
+	for i := 0; i < 2; i++ {
+		if w := serve(h, http.MethodHead, "/once"); w.Code != http.StatusNotFound || w.Header().Get("Location") != "" {
+			t.Fatalf("HEAD /once = %d %q, want 404 without a Location", w.Code, w.Header().Get("Location"))
+		}
+	}
+	if w := serve(h, http.MethodHead, "/a"); w.Code != http.StatusFound {
+		t.Errorf("HEAD /a = %d, want 302", w.Code)
+	}
+	if u, ok, err := s.Peek("/once"); !ok || err != nil || u != "https://o.com" {
+		t.Fatalf("Peek(/once) = %q, %v, %v", u, ok, err)
+	}
+	if _, ok, _ := s.Reveal("/once"); ok {
+		t.Error("Reveal(/once) found a single-use link")
+	}
+	if u, ok, err := Reveal(s, "/a"); !ok || err != nil || u != "https://a.com" {
+		t.Fatalf("Reveal(/a) = %q, %v, %v", u, ok, err)
+	}
+	if n, _ := s.Hits("/a"); n != 0 {
+		t.Errorf("Hits(/a) = %d after HEAD, Peek and Reveal, want 0", n)
+	}
+
+	expectStatus(t, h, "/once", http.StatusFound)
+	expectStatus(t, h, "/once", http.StatusNotFound)
+	serve(h, http.MethodGet, "/a")
+	if n, _ := s.Hits("/a"); n != 1 {
+		t.Errorf("Hits(/a) = %d after GET, want 1", n)
+	}
+}
+
+func TestBoltCreated(t *testing.T) {
+	s := openTestBolt(t, BoltOptions{})
+	if err := s.Put("/a", "https://a.com"); err != nil {
+		t.Fatal(err)
+	}
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(s.bucket).Put([]byte("/legacy"), []byte("https://l.com"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, ok, err := s.Created("/a")
+	if err != nil || !ok || time.Since(c) > time.Minute {
+		t.Errorf("Created(/a) = %v, %v, %v, want about now", c, ok, err)
+	}
+	c, ok, err = s.Created("/legacy")
+	if err != nil || !ok || !c.IsZero() {
+		t.Errorf("Created(/legacy) = %v, %v, %v, want the zero time", c, ok, err)
+	}
+	if _, ok, _ := s.Created("/x"); ok {
+		t.Error("Created(/x) reported a missing path")
+	}
+}
+
+func TestBoltTimeout(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "t.db")
+	s, err := OpenBoltStore(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	start := time.Now()
+	if _, err := OpenBoltStoreWithOptions(f, BoltOptions{Timeout: 100 * time.Millisecond}); err == nil {
+		t.Fatal("expected a timeout opening a locked file")
+	}
+	if d := time.Since(start); d < 100*time.Millisecond || d > 2*time.Second {
+		t.Errorf("gave up after %v, want about 100ms", d)
+	}
+}
+
+func TestBoltRetry(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "l.db")
+	held, err := bolt.Open(f, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = OpenBoltStoreWithOptions(f, BoltOptions{Timeout: 20 * time.Millisecond, Retries: 1, RetryBackoff: time.Millisecond})
+	if err != bolt.ErrTimeout {
+		t.Fatalf("error = %v, want %v", err, bolt.ErrTimeout)
+	}
+
+	go func() {
+		time.Sleep(150 * time.Millisecond)
+		held.Close()
+	}()
+	start := time.Now()
+	s, err := OpenBoltStoreWithOptions(f, BoltOptions{Timeout: 20 * time.Millisecond, Retries: 6, RetryBackoff: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+	if time.Since(start) < 150*time.Millisecond {
+		t.Error("opened the file while it was still locked")
+	}
+}
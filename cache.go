@@ -0,0 +1,294 @@
+package urlshort
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// CachingStore is a Store that keeps the results of recent
+// lookups in another Store in memory, so hot paths do not query
+// the underlying store on every request. Results, including
+// misses, are kept for a fixed TTL and the least recently used
+// ones are evicted once the cache is full. Errors are never
+// cached.
+//
+// Writes made through the CachingStore invalidate the cached
+// result for the path. Writes made to the underlying store
+// directly, for instance by another process, are only seen once
+// the cached result expires.
+//
+// Stores whose lookups have side effects, such as a BoltStore
+// consuming single-use redirects or counting hits, and stores
+// whose targets depend on the request, such as those built from
+// entries with per-device targets, are not cached: every lookup
+// is passed to them.
+type CachingStore struct {
+	store       Store
+	size        int
+	ttl         time.Duration
+	now         func() time.Time
+	passThrough bool
+
+	mu     sync.Mutex
+	lru    *list.List // of *cacheEntry, most recently used first
+	items  map[string]*list.Element
+	hits   uint64
+	misses uint64
+}
+
+// cacheEntry is a cached lookup result.
+type cacheEntry struct {
+	path    string
+	url     string
+	ok      bool
+	expires time.Time
+}
+
+// CacheStats counts the lookups served by a CachingStore.
+type CacheStats struct {
+	// Hits is the number of lookups served from the cache.
+	Hits uint64
+	// Misses is the number of lookups passed to the underlying
+	// store.
+	Misses uint64
+}
+
+// NewCachingStore returns a CachingStore holding up to size
+// results from store for ttl each.
+func NewCachingStore(store Store, size int, ttl time.Duration) *CachingStore {
+	return NewCachingStoreWithClock(store, size, ttl, SystemClock)
+}
+
+// NewCachingStoreWithClock behaves like NewCachingStore but
+// expires results by the time of clock.
+func NewCachingStoreWithClock(store Store, size int, ttl time.Duration, clock Clock) *CachingStore {
+	return &CachingStore{
+		store:       store,
+		size:        size,
+		ttl:         ttl,
+		now:         clock.Now,
+		passThrough: uncacheable(store),
+		lru:         list.New(),
+		items:       make(map[string]*list.Element),
+	}
+}
+
+// uncacheable reports whether lookups in store must not be
+// cached, because they have side effects or their results depend
+// on the request.
+func uncacheable(store Store) bool {
+	switch store.(type) {
+	case Peeker, HitStore, requestStore:
+		return true
+	}
+	return false
+}
+
+// Lookup returns the cached result for path, or looks it up in
+// the underlying store.
+func (c *CachingStore) Lookup(path string) (string, bool, error) {
+	return c.LookupContext(context.Background(), path)
+}
+
+// LookupContext behaves like Lookup but passes ctx to the
+// underlying store.
+func (c *CachingStore) LookupContext(ctx context.Context, path string) (string, bool, error) {
+	return c.lookup(path, func() (string, bool, error) {
+		return lookupContext(ctx, c.store, path)
+	})
+}
+
+// lookupRequest behaves like LookupContext but passes r to the
+// underlying store, whose targets may depend on it.
+func (c *CachingStore) lookupRequest(r *http.Request, path string) (string, bool, error) {
+	return c.lookup(path, func() (string, bool, error) {
+		return lookupRequest(r, c.store, path)
+	})
+}
+
+// lookup returns the cached result for path, or the result of
+// find, which it caches.
+func (c *CachingStore) lookup(path string, find func() (string, bool, error)) (string, bool, error) {
+	if url, ok, cached := c.get(path); cached {
+		return url, ok, nil
+	}
+	url, ok, err := find()
+	if err != nil {
+		return "", false, err
+	}
+	c.add(path, url, ok)
+	return url, ok, nil
+}
+
+// redirectResponse returns the response the underlying store
+// gives path, if it customizes one.
+func (c *CachingStore) redirectResponse(path string) response {
+	if rs, ok := c.store.(responseStore); ok {
+		return rs.redirectResponse(path)
+	}
+	return response{}
+}
+
+// Peek looks up path in the underlying store without side
+// effects, bypassing the cache.
+func (c *CachingStore) Peek(path string) (string, bool, error) {
+	return peek(c.store, path)
+}
+
+// Reveal looks up path in the underlying store with Reveal,
+// bypassing the cache.
+func (c *CachingStore) Reveal(path string) (string, bool, error) {
+	return Reveal(c.store, path)
+}
+
+// get returns the cached result for path, if it has one that has
+// not expired.
+func (c *CachingStore) get(path string) (url string, ok, cached bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, found := c.items[path]; found && !c.passThrough {
+		e := elem.Value.(*cacheEntry)
+		if c.now().Before(e.expires) {
+			c.hits++
+			c.lru.MoveToFront(elem)
+			return e.url, e.ok, true
+		}
+		c.remove(elem)
+	}
+	c.misses++
+	return "", false, false
+}
+
+// add caches a result, evicting the least recently used one if
+// the cache is full.
+func (c *CachingStore) add(path, url string, ok bool) {
+	if c.size <= 0 || c.passThrough {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, found := c.items[path]; found {
+		c.remove(elem)
+	}
+	for c.lru.Len() >= c.size {
+		c.remove(c.lru.Back())
+	}
+	c.items[path] = c.lru.PushFront(&cacheEntry{path: path, url: url, ok: ok, expires: c.now().Add(c.ttl)})
+}
+
+// remove drops elem from the cache. c.mu must be held.
+func (c *CachingStore) remove(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.items, elem.Value.(*cacheEntry).path)
+}
+
+// Invalidate drops the cached result for path, if any.
+func (c *CachingStore) Invalidate(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, found := c.items[path]; found {
+		c.remove(elem)
+	}
+}
+
+// Stats returns the number of lookups served from the cache and
+// from the underlying store so far.
+func (c *CachingStore) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{Hits: c.hits, Misses: c.misses}
+}
+
+// Errors returned by wrappers around a Store that lacks the
+// capability a call needs.
+var (
+	errNotWritable = errors.New("store cannot change mappings")
+	errNotListable = errors.New("store cannot list mappings")
+	errNoHits      = errors.New("store does not count hits")
+)
+
+// Put stores a redirect in the underlying store, which must be a
+// WritableStore, and invalidates the cached result for path.
+func (c *CachingStore) Put(path, url string) error {
+	ws, ok := c.store.(WritableStore)
+	if !ok {
+		return errNotWritable
+	}
+	defer c.Invalidate(path)
+	return ws.Put(path, url)
+}
+
+// Insert stores a redirect in the underlying store, which must be
+// a WritableStore, unless it already has one for path, and
+// invalidates the cached result for path.
+func (c *CachingStore) Insert(path, url string) (bool, error) {
+	ws, ok := c.store.(WritableStore)
+	if !ok {
+		return false, errNotWritable
+	}
+	defer c.Invalidate(path)
+	return insert(ws, path, url)
+}
+
+// Delete removes a redirect from the underlying store, which must
+// be a WritableStore, and invalidates the cached result for path.
+func (c *CachingStore) Delete(path string) error {
+	ws, ok := c.store.(WritableStore)
+	if !ok {
+		return errNotWritable
+	}
+	defer c.Invalidate(path)
+	return ws.Delete(path)
+}
+
+// Each enumerates the underlying store, which must be a
+// ListableStore, bypassing the cache.
+func (c *CachingStore) Each(fn func(path, url string) error) error {
+	ls, ok := c.store.(ListableStore)
+	if !ok {
+		return errNotListable
+	}
+	return ls.Each(fn)
+}
+
+// EachHit enumerates the hit counts of the underlying store,
+// which must be a HitStore.
+func (c *CachingStore) EachHit(fn func(path string, hits uint64) error) error {
+	hs, ok := c.store.(HitStore)
+	if !ok {
+		return errNoHits
+	}
+	return hs.EachHit(fn)
+}
+
+// Created returns the creation time of path from the underlying
+// store, which must be a CreatedStore.
+func (c *CachingStore) Created(path string) (time.Time, bool, error) {
+	cs, ok := c.store.(CreatedStore)
+	if !ok {
+		return time.Time{}, false, errNoCreated
+	}
+	return cs.Created(path)
+}
+
+// EachCreated enumerates the creation times of the underlying
+// store, which must be a CreatedStore.
+func (c *CachingStore) EachCreated(fn func(path string, created time.Time) error) error {
+	cs, ok := c.store.(CreatedStore)
+	if !ok {
+		return errNoCreated
+	}
+	return cs.EachCreated(fn)
+}
+
+// Ping checks the underlying store if it is a Pinger.
+func (c *CachingStore) Ping(ctx context.Context) error {
+	if p, ok := c.store.(Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
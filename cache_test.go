@@ -0,0 +1,140 @@
+package urlshort
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// countStore counts the lookups passed on to its
+// MutableMapStore.
+type countStore struct {
+	*MutableMapStore
+	n int
+}
+
+func (c *countStore) Lookup(path string) (string, bool, error) {
+	c.n++
+	return c.MutableMapStore.Lookup(path)
+}
+
+func TestCachingStore(t *testing.T) {
+	cs := &countStore{MutableMapStore: NewMutableMapStore(map[string]string{"/a": "https://a.com"})}
+	clock := NewFakeClock(time.Unix(0, 0))
+	c := NewCachingStoreWithClock(cs, 2, time.Minute, clock)
+
+	for i := 0; i < 3; i++ {
+		if u, ok, _ := c.Lookup("/a"); !ok || u != "https://a.com" {
+			t.Fatalf("Lookup(/a) = %q, %v", u, ok)
+		}
+	}
+	if cs.n != 1 || c.Stats() != (CacheStats{Hits: 2, Misses: 1}) {
+		t.Fatalf("after three lookups: %d store lookups, stats %+v", cs.n, c.Stats())
+	}
+
+	clock.Advance(2 * time.Minute)
+	c.Lookup("/a")
+	if cs.n != 2 {
+		t.Errorf("expired entry was served from the cache")
+	}
+
+	c.Lookup("/b")
+	if err := c.Put("/b", "https://b.com"); err != nil {
+		t.Fatal(err)
+	}
+	if u, ok, _ := c.Lookup("/b"); !ok || u != "https://b.com" {
+		t.Errorf("Put did not invalidate the cached miss: %q, %v", u, ok)
+	}
+
+	c.Lookup("/c")
+	c.Lookup("/d")
+	n := cs.n
+	c.Lookup("/a")
+	if cs.n != n+1 {
+		t.Errorf("least recently used entry was not evicted")
+	}
+
+	if err := NewCachingStore(MapStore{}, 1, time.Second).Put("/x", "https://x"); err == nil {
+		t.Error("expected an error writing through to a read-only store")
+	}
+}
+
+func TestCachingStorePeek(t *testing.T) {
+	s := openTestBolt(t, BoltOptions{})
+	s.PutSingleUse("/c", "https://c.com")
+	if _, ok, _ := peek(NewCachingStore(s, 10, time.Minute), "/c"); !ok {
+		t.Fatal("Peek did not find the single-use link")
+	}
+	if _, ok, _ := s.Lookup("/c"); !ok {
+		t.Error("Peek consumed the single-use link")
+	}
+}
+
+func TestCachingStorePassThrough(t *testing.T) {
+	s := openTestBolt(t, BoltOptions{CountHits: true})
+	s.Put("/a", "https://a.com")
+	s.PutSingleUse("/once", "https://o.com")
+	c := NewCachingStore(s, 10, time.Minute)
+	h := StoreHandler(c, notFound)
+
+	expectStatus(t, h, "/once", http.StatusFound)
+	expectStatus(t, h, "/once", http.StatusNotFound)
+	for i := 0; i < 3; i++ {
+		expectStatus(t, h, "/a", http.StatusFound)
+	}
+	if n, _ := s.Hits("/a"); n != 3 {
+		t.Errorf("Hits(/a) = %d through the cache, want 3", n)
+	}
+
+	doc := "- path: /app\n  url: https://app.com\n  devices: {mobile: https://m.app.com}\n"
+	entries, err := decodeYAMLEntries(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	es, err := Options{}.entryStore(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h = StoreHandler(NewCachingStore(es, 10, time.Minute), notFound)
+	expectRedirect(t, h, "/app", http.StatusFound, "https://app.com")
+	r := httptest.NewRequest(http.MethodGet, "/app", nil)
+	r.Header.Set("User-Agent", "Mozilla/5.0 (iPhone; CPU iPhone OS 16_0 like Mac OS X) Mobile/15E148")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if got := w.Header().Get("Location"); got != "https://m.app.com" {
+		t.Errorf("mobile client redirected to %q, want the mobile target", got)
+	}
+}
+
+func TestCachingStoreCapabilities(t *testing.T) {
+	s := openTestBolt(t, BoltOptions{CountHits: true})
+	s.PutSingleUse("/once", "https://o.com")
+	c := NewCachingStore(s, 10, time.Minute)
+
+	if ok, err := c.Insert("/a", "https://a.com"); !ok || err != nil {
+		t.Fatalf("Insert(/a) = %v, %v", ok, err)
+	}
+	if ok, _ := c.Insert("/a", "https://b.com"); ok {
+		t.Error("Insert replaced an existing link")
+	}
+	if _, ok, _ := Reveal(c, "/once"); ok {
+		t.Error("Reveal found a single-use link through the cache")
+	}
+	c.Lookup("/a")
+	if top, err := TopLinks(c, 1); err != nil || len(top) != 1 || top[0].Hits != 1 {
+		t.Errorf("TopLinks = %+v, %v", top, err)
+	}
+	if _, ok, err := c.Created("/a"); !ok || err != nil {
+		t.Errorf("Created(/a) = %v, %v", ok, err)
+	}
+
+	m := NewCachingStore(MapStore{}, 10, time.Minute)
+	if _, err := m.Insert("/a", "https://a.com"); err == nil {
+		t.Error("expected an error inserting into a read-only store")
+	}
+	if _, err := TopLinks(m, 1); err == nil {
+		t.Error("expected an error listing hits of a store that does not count them")
+	}
+}
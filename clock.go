@@ -0,0 +1,55 @@
+package urlshort
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the current time. Handlers and stores that check
+// expiry or record timestamps accept one, so tests can control
+// the time they see.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the Clock reading the system time, which is used
+// when no other Clock is configured.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// FakeClock is a Clock whose time only changes when it is set or
+// advanced, for tests of expiry and scheduling. It is safe for
+// concurrent use.
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFakeClock returns a FakeClock set to now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the time c is set to.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Set sets c to now.
+func (c *FakeClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+// Advance moves c forward by d.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
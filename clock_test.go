@@ -0,0 +1,56 @@
+package urlshort
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	if !clock.Now().Equal(start) {
+		t.Fatalf("Now = %v, want %v", clock.Now(), start)
+	}
+	clock.Advance(time.Hour)
+	if want := start.Add(time.Hour); !clock.Now().Equal(want) {
+		t.Errorf("after Advance, Now = %v, want %v", clock.Now(), want)
+	}
+	clock.Set(start)
+	if !clock.Now().Equal(start) {
+		t.Errorf("after Set, Now = %v, want %v", clock.Now(), start)
+	}
+}
+
+func TestClockOption(t *testing.T) {
+	clock := NewFakeClock(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+	doc := "- path: /a\n  url: https://a.com\n  expires: 2030-01-02T00:00:00Z\n"
+	h, err := YAMLHandlerWithOptions([]byte(doc), notFound, Options{Clock: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, h, "/a", http.StatusFound)
+	clock.Advance(48 * time.Hour)
+	expectStatus(t, h, "/a", http.StatusNotFound)
+}
+
+func TestClockStores(t *testing.T) {
+	clock := NewFakeClock(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+	s := openTestBolt(t, BoltOptions{Clock: clock})
+	s.Put("/x", "https://x.com")
+	if c, _, _ := s.Created("/x"); !c.Equal(clock.Now()) {
+		t.Errorf("Created = %v, want %v", c, clock.Now())
+	}
+	if c, _, _ := mustTenant(t, s, "t").Created("/none"); !c.IsZero() {
+		t.Errorf("tenant Created(/none) = %v, want zero", c)
+	}
+
+	cs := NewCachingStoreWithClock(MapStore{"/a": "https://a.com"}, 10, time.Minute, clock)
+	cs.Lookup("/a")
+	cs.Lookup("/a")
+	clock.Advance(2 * time.Minute)
+	cs.Lookup("/a")
+	if st := cs.Stats(); st.Hits != 1 || st.Misses != 2 {
+		t.Errorf("cache stats = %+v, want 1 hit and 2 misses", st)
+	}
+}
@@ -0,0 +1,107 @@
+package urlshort
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+)
+
+// Base62Alphabet is the alphabet GenerateCode draws from.
+const Base62Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// DefaultCodeLength is the length of the codes minted by
+// CreateWithGeneratedCode.
+const DefaultCodeLength = 7
+
+// defaultCodeAttempts is how many codes CreateWithGeneratedCode
+// tries before giving up.
+const defaultCodeAttempts = 10
+
+// CodeGenerator mints random short codes. The zero value
+// generates DefaultCodeLength characters of Base62Alphabet.
+type CodeGenerator struct {
+	// Alphabet is the set of characters codes are made of.
+	// Empty means Base62Alphabet.
+	Alphabet string
+
+	// Length is the number of characters in a code. Zero means
+	// DefaultCodeLength.
+	Length int
+
+	// Attempts is how many codes Create tries before giving up
+	// because they are all taken. Zero means 10.
+	Attempts int
+}
+
+// GenerateCode returns a random code of n base62 characters read
+// from crypto/rand. It panics if the system's secure random
+// number generator fails.
+func GenerateCode(n int) string {
+	code, err := CodeGenerator{Length: n}.Generate()
+	if err != nil {
+		panic(err)
+	}
+	return code
+}
+
+// CreateWithGeneratedCode stores a redirect to url under a newly
+// generated path of DefaultCodeLength base62 characters and
+// returns the path. See CodeGenerator.Create.
+func CreateWithGeneratedCode(store WritableStore, url string) (string, error) {
+	return CodeGenerator{}.Create(store, url)
+}
+
+// Generate returns a random code read from crypto/rand.
+func (g CodeGenerator) Generate() (string, error) {
+	alphabet := []rune(g.Alphabet)
+	if len(alphabet) == 0 {
+		alphabet = []rune(Base62Alphabet)
+	}
+	length := g.Length
+	if length == 0 {
+		length = DefaultCodeLength
+	}
+	if length < 0 {
+		return "", fmt.Errorf("invalid code length: %d", length)
+	}
+
+	max := big.NewInt(int64(len(alphabet)))
+	code := make([]rune, length)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", fmt.Errorf("generate code: %s", err)
+		}
+		code[i] = alphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+// Create stores a redirect to url under "/" followed by a newly
+// generated code and returns that path. Codes already present in
+// store are skipped; an error is returned if every attempt
+// collides with an existing path. url must be an absolute URL.
+func (g CodeGenerator) Create(store WritableStore, url string) (string, error) {
+	if err := checkAbsoluteURL(url); err != nil {
+		return "", err
+	}
+	attempts := g.Attempts
+	if attempts <= 0 {
+		attempts = defaultCodeAttempts
+	}
+	for i := 0; i < attempts; i++ {
+		code, err := g.Generate()
+		if err != nil {
+			return "", err
+		}
+		path := "/" + code
+		inserted, err := insert(store, path, url)
+		if err != nil {
+			return "", err
+		}
+		if inserted {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no free code found after %d attempts", attempts)
+}
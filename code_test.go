@@ -0,0 +1,38 @@
+package urlshort
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateCode(t *testing.T) {
+	c := GenerateCode(12)
+	if len(c) != 12 || strings.Trim(c, Base62Alphabet) != "" {
+		t.Errorf("GenerateCode(12) = %q", c)
+	}
+}
+
+func TestCodeGeneratorCreate(t *testing.T) {
+	s := openTestBolt(t, BoltOptions{})
+	s.Put("/a", "https://taken.com")
+
+	g := CodeGenerator{Alphabet: "a", Length: 1, Attempts: 3}
+	if _, err := g.Create(s, "https://x.com"); err == nil {
+		t.Error("expected an error when every code is taken")
+	}
+	g.Alphabet = "b"
+	if p, err := g.Create(s, "https://x.com"); err != nil || p != "/b" {
+		t.Errorf("Create = %q, %v, want /b", p, err)
+	}
+	if u, _, _ := s.Lookup("/a"); u != "https://taken.com" {
+		t.Errorf("Create overwrote /a with %q", u)
+	}
+
+	p, err := CreateWithGeneratedCode(s, "https://y.com")
+	if err != nil || len(p) != DefaultCodeLength+1 {
+		t.Fatalf("CreateWithGeneratedCode = %q, %v", p, err)
+	}
+	if u, _, _ := s.Lookup(p); u != "https://y.com" {
+		t.Errorf("Lookup(%s) = %q, want https://y.com", p, u)
+	}
+}
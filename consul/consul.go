@@ -0,0 +1,119 @@
+// Package consul serves redirects stored as keys under a prefix in
+// the Consul KV store.
+package consul
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bcpoole/urlshort"
+	"github.com/hashicorp/consul/api"
+)
+
+// retryDelay is how long a Handler waits before retrying a failed
+// blocking query.
+const retryDelay = 5 * time.Second
+
+// Handler loads every key under prefix from the Consul KV store and redirects the path named
+// by the rest of the key to the url stored as its value, so the key prefix + "/promo" redirects
+// /promo. Else falls back to provided Handler. Keys with an empty value, such as folders, are
+// ignored. The mapping is kept up to date by blocking queries in the background: new and
+// changed keys take effect as soon as Consul reports them and deleted keys are removed. The
+// returned function stops watching.
+func Handler(client *api.Client, prefix string, fallback http.Handler) (http.HandlerFunc, func() error, error) {
+	if client == nil {
+		return nil, nil, errors.New("consul client is nil")
+	}
+	return handler(client.KV(), prefix, fallback)
+}
+
+// kvLister is the part of *api.KV used by Handler.
+type kvLister interface {
+	List(prefix string, q *api.QueryOptions) (api.KVPairs, *api.QueryMeta, error)
+}
+
+func handler(kv kvLister, prefix string, fallback http.Handler) (http.HandlerFunc, func() error, error) {
+	store := &kvStore{prefix: prefix}
+	index, err := store.load(kv, &api.QueryOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			q := (&api.QueryOptions{WaitIndex: index}).WithContext(ctx)
+			next, err := store.load(kv, q)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				log.Printf("urlshort: watch consul prefix %s: %v", prefix, err)
+				select {
+				case <-time.After(retryDelay):
+				case <-ctx.Done():
+					return
+				}
+				continue
+			}
+			// Consul may reset its index, for instance after a
+			// restore; starting over from zero resynchronizes.
+			if next < index {
+				next = 0
+			}
+			index = next
+		}
+	}()
+
+	stop := func() error {
+		cancel()
+		<-done
+		return nil
+	}
+	return urlshort.StoreHandler(store, fallback), stop, nil
+}
+
+// kvStore is a urlshort.Store holding a copy of the keys under a prefix
+// in Consul.
+type kvStore struct {
+	prefix string
+
+	mu    sync.RWMutex
+	paths map[string]string
+}
+
+func (s *kvStore) Lookup(path string) (string, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	url, ok := s.paths[path]
+	return url, ok, nil
+}
+
+// load replaces the mapping with the keys under the prefix, as
+// returned by a query with q, and returns the index they were read
+// at. With a WaitIndex set, the query blocks until the keys change
+// or Consul's wait time passes.
+func (s *kvStore) load(kv kvLister, q *api.QueryOptions) (uint64, error) {
+	pairs, meta, err := kv.List(s.prefix, q)
+	if err != nil {
+		return 0, err
+	}
+	paths := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		if len(pair.Value) == 0 {
+			continue
+		}
+		paths[strings.TrimPrefix(pair.Key, s.prefix)] = string(pair.Value)
+	}
+	s.mu.Lock()
+	s.paths = paths
+	s.mu.Unlock()
+	return meta.LastIndex, nil
+}
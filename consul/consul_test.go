@@ -0,0 +1,115 @@
+package consul
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// status returns the status of a GET for target sent to h.
+func status(h http.Handler, target string) int {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+	return w.Code
+}
+
+// fakeConsul serves the blocking KV list queries of the Consul HTTP
+// API from a map.
+type fakeConsul struct {
+	mu      sync.Mutex
+	index   uint64
+	kv      map[string]string
+	changed chan struct{}
+}
+
+func newFakeConsul(kv map[string]string) *fakeConsul {
+	return &fakeConsul{index: 1, kv: kv, changed: make(chan struct{})}
+}
+
+// set stores value under key, or deletes key if value is empty,
+// and wakes up blocked queries.
+func (f *fakeConsul) set(key, value string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if value == "" {
+		delete(f.kv, key)
+	} else {
+		f.kv[key] = value
+	}
+	f.index++
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+func (f *fakeConsul) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	prefix := strings.TrimPrefix(r.URL.Path, "/v1/kv/")
+	wait, _ := strconv.ParseUint(r.URL.Query().Get("index"), 10, 64)
+	for {
+		f.mu.Lock()
+		if f.index > wait {
+			var pairs []map[string]interface{}
+			for k, v := range f.kv {
+				if strings.HasPrefix(k, prefix) {
+					pairs = append(pairs, map[string]interface{}{"Key": k, "Value": []byte(v)})
+				}
+			}
+			w.Header().Set("X-Consul-Index", strconv.FormatUint(f.index, 10))
+			f.mu.Unlock()
+			json.NewEncoder(w).Encode(pairs)
+			return
+		}
+		changed := f.changed
+		f.mu.Unlock()
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	f := newFakeConsul(map[string]string{
+		"links/a": "https://a.com",
+		"links/b": "https://b.com",
+		"links/":  "",
+		"other/c": "https://c.com",
+	})
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+	client, err := api.NewClient(&api.Config{Address: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, stop, err := Handler(client, "links", http.NotFoundHandler())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	if code := status(h, "/a"); code != http.StatusFound {
+		t.Errorf("GET /a = %d, want 302", code)
+	}
+	if code := status(h, "/c"); code != http.StatusNotFound {
+		t.Errorf("GET /c = %d, want 404", code)
+	}
+
+	f.set("links/a", "")
+	f.set("links/d", "https://d.com")
+	deadline := time.Now().Add(5 * time.Second)
+	for status(h, "/d") != http.StatusFound || status(h, "/a") != http.StatusNotFound {
+		if time.Now().After(deadline) {
+			t.Fatal("changes to the KV store were not picked up")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if code := status(h, "/b"); code != http.StatusFound {
+		t.Errorf("GET /b = %d, want 302", code)
+	}
+}
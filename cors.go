@@ -0,0 +1,68 @@
+package urlshort
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORS describes the cross-origin headers of JSON responses, so
+// browser applications on other origins can resolve paths. See
+// Options.CORS.
+type CORS struct {
+	// Origins are the origins, such as https://app.example.com,
+	// allowed to read JSON responses. "*" allows any origin.
+	Origins []string
+
+	// Headers are the request headers, besides the CORS-safelisted
+	// ones, that preflight requests may ask to send.
+	Headers []string
+
+	// MaxAge is how long browsers may cache the answer to a
+	// preflight request. Zero leaves it to the browser.
+	MaxAge time.Duration
+}
+
+// allowOrigin sets Access-Control-Allow-Origin on w if the origin
+// of r is allowed by c, and reports whether it is.
+func (c *CORS) allowOrigin(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+	w.Header().Add("Vary", "Origin")
+	for _, allowed := range c.Origins {
+		if allowed == "*" {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			return true
+		}
+		if allowed == origin {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			return true
+		}
+	}
+	return false
+}
+
+// preflight answers the preflight request r with
+// http.StatusNoContent, allowing the request it announces if its
+// origin is allowed by c.
+func (c *CORS) preflight(w http.ResponseWriter, r *http.Request) {
+	if c.allowOrigin(w, r) {
+		w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, OPTIONS")
+		if len(c.Headers) > 0 {
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(c.Headers, ", "))
+		}
+		if c.MaxAge > 0 {
+			w.Header().Set("Access-Control-Max-Age", strconv.FormatInt(int64(c.MaxAge/time.Second), 10))
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// isPreflight reports whether r is a CORS preflight request.
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions && r.Header.Get("Origin") != "" &&
+		r.Header.Get("Access-Control-Request-Method") != ""
+}
@@ -0,0 +1,58 @@
+package urlshort
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCORS(t *testing.T) {
+	m := MapStore{"/a": "https://a.com"}
+	if _, err := StoreHandlerWithOptions(m, notFound, Options{CORS: &CORS{Origins: []string{"*"}}}); err == nil {
+		t.Fatal("expected an error for a wildcard origin")
+	}
+	h, err := StoreHandlerWithOptions(m, notFound, Options{
+		JSONResponse: true,
+		CORS:         &CORS{Origins: []string{"https://app.com"}, Headers: []string{"X-Token"}, MaxAge: time.Hour},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := func(method, origin, accept string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/a", nil)
+		r.Header.Set("Origin", origin)
+		if method == http.MethodOptions {
+			r.Header.Set("Access-Control-Request-Method", http.MethodGet)
+		}
+		if accept != "" {
+			r.Header.Set("Accept", accept)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	w := request(http.MethodOptions, "https://app.com", "")
+	hd := w.Header()
+	if w.Code != http.StatusNoContent || hd.Get("Access-Control-Allow-Origin") != "https://app.com" ||
+		hd.Get("Access-Control-Allow-Headers") != "X-Token" || hd.Get("Access-Control-Max-Age") != "3600" ||
+		hd.Get("Access-Control-Allow-Methods") == "" {
+		t.Errorf("preflight = %d %v", w.Code, hd)
+	}
+
+	w = request(http.MethodOptions, "https://evil.com", "")
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("preflight from another origin = %d %v", w.Code, w.Header())
+	}
+
+	w = request(http.MethodGet, "https://app.com", "application/json")
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "https://app.com" {
+		t.Errorf("JSON GET = %d %v", w.Code, w.Header())
+	}
+
+	w = request(http.MethodGet, "https://app.com", "text/html")
+	if w.Code != http.StatusFound || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("redirecting GET = %d %v", w.Code, w.Header())
+	}
+}
@@ -0,0 +1,57 @@
+package urlshort
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Device is a coarse classification of the client making a
+// request.
+type Device int
+
+// The devices told apart by DeviceOf.
+const (
+	Desktop Device = iota
+	Mobile
+	Tablet
+)
+
+func (d Device) String() string {
+	switch d {
+	case Desktop:
+		return "desktop"
+	case Mobile:
+		return "mobile"
+	case Tablet:
+		return "tablet"
+	}
+	return fmt.Sprintf("Device(%d)", int(d))
+}
+
+// parseDevice parses the name of a Device as used in mapping
+// documents.
+func parseDevice(name string) (Device, error) {
+	for _, d := range []Device{Desktop, Mobile, Tablet} {
+		if strings.EqualFold(name, d.String()) {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown device %q", name)
+}
+
+// DeviceOf classifies the client of r from its User-Agent header.
+// The classification relies on a few well known tokens rather
+// than a full User-Agent database; anything not recognized as a
+// phone or tablet is a Desktop.
+func DeviceOf(r *http.Request) Device {
+	ua := r.UserAgent()
+	switch {
+	case strings.Contains(ua, "iPad"), strings.Contains(ua, "Tablet"),
+		strings.Contains(ua, "Android") && !strings.Contains(ua, "Mobile"):
+		return Tablet
+	case strings.Contains(ua, "Mobi"), strings.Contains(ua, "iPhone"), strings.Contains(ua, "iPod"):
+		return Mobile
+	}
+	return Desktop
+}
@@ -0,0 +1,49 @@
+package urlshort
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDeviceOf(t *testing.T) {
+	for ua, want := range map[string]Device{
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 Mobile/15E148":   Mobile,
+		"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 Chrome/120 Mobile Safari/537.36": Mobile,
+		"Mozilla/5.0 (Linux; Android 13; SM-X700) AppleWebKit/537.36 Chrome/120 Safari/537.36":        Tablet,
+		"Mozilla/5.0 (iPad; CPU OS 17_0 like Mac OS X) AppleWebKit/605.1.15":                          Tablet,
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 Chrome/120 Safari/537.36":       Desktop,
+		"": Desktop,
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("User-Agent", ua)
+		if got := DeviceOf(r); got != want {
+			t.Errorf("DeviceOf(%q) = %v, want %v", ua, got, want)
+		}
+	}
+}
+
+func TestDeviceTargets(t *testing.T) {
+	doc := "- path: /app\n  url: https://web.com\n  devices:\n    mobile: https://m.com\n    tablet: https://t.com\n"
+	h, err := YAMLHandler([]byte(doc), notFound)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for ua, want := range map[string]string{
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) Mobile/15E148": "https://m.com",
+		"Mozilla/5.0 (Linux; Android 13; SM-X700) Chrome/120 Safari/537.36":    "https://t.com",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) Chrome/120 Safari/537.36":   "https://web.com",
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/app", nil)
+		r.Header.Set("User-Agent", ua)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if got := w.Header().Get("Location"); got != want {
+			t.Errorf("User-Agent %q: Location = %q, want %q", ua, got, want)
+		}
+	}
+
+	if _, err := YAMLHandler([]byte("- path: /x\n  url: https://x.com\n  devices: {watch: https://w.com}\n"), notFound); err == nil {
+		t.Error("expected an error for an unknown device")
+	}
+}
@@ -0,0 +1,78 @@
+// Package dynamodb serves redirects stored as items in a DynamoDB
+// table.
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/bcpoole/urlshort"
+)
+
+// GetItemAPI is the part of *dynamodb.Client used by Store.
+type GetItemAPI interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+}
+
+// Store is a urlshort.Store backed by a DynamoDB table whose
+// partition key is the request path. Every lookup issues a
+// GetItem, so the store keeps no state of its own.
+type Store struct {
+	client   GetItemAPI
+	table    string
+	pathAttr string
+	urlAttr  string
+}
+
+// NewStore returns a Store that looks up paths in table, keyed by
+// the string attribute pathAttr, and reads the url from the string
+// attribute urlAttr.
+func NewStore(client GetItemAPI, table, pathAttr, urlAttr string) *Store {
+	return &Store{client: client, table: table, pathAttr: pathAttr, urlAttr: urlAttr}
+}
+
+// Lookup returns the url of the item keyed by path. A missing
+// item is not an error, but an item without a string url
+// attribute is.
+func (s *Store) Lookup(path string) (string, bool, error) {
+	return s.LookupContext(context.Background(), path)
+}
+
+// LookupContext behaves like Lookup but cancels the GetItem when
+// ctx is done.
+func (s *Store) LookupContext(ctx context.Context, path string) (string, bool, error) {
+	out, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(s.table),
+		Key: map[string]types.AttributeValue{
+			s.pathAttr: &types.AttributeValueMemberS{Value: path},
+		},
+		ProjectionExpression:     aws.String("#url"),
+		ExpressionAttributeNames: map[string]string{"#url": s.urlAttr},
+	})
+	if err != nil {
+		return "", false, err
+	}
+	if out.Item == nil {
+		return "", false, nil
+	}
+	url, ok := out.Item[s.urlAttr].(*types.AttributeValueMemberS)
+	if !ok {
+		return "", false, fmt.Errorf("item for %s has no string %s attribute", path, s.urlAttr)
+	}
+	return url.Value, true, nil
+}
+
+// Handler looks up each request path in the DynamoDB table with a GetItem and redirects to the
+// stored url. Else falls back to provided Handler, including when DynamoDB returns an error such
+// as a throttling response. See NewStore for the meaning of pathAttr and urlAttr.
+func Handler(client *dynamodb.Client, table, pathAttr, urlAttr string, fallback http.Handler) (http.HandlerFunc, error) {
+	if client == nil {
+		return nil, errors.New("dynamodb client is nil")
+	}
+	return urlshort.StoreHandler(NewStore(client, table, pathAttr, urlAttr), fallback), nil
+}
@@ -0,0 +1,69 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/bcpoole/urlshort"
+)
+
+// fakeDynamoDB answers GetItem requests for the links table from a
+// map, or with err if it is set.
+type fakeDynamoDB struct {
+	t     *testing.T
+	items map[string]string
+	err   error
+}
+
+func (f fakeDynamoDB) GetItem(ctx context.Context, in *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	if *in.TableName != "links" || in.ExpressionAttributeNames["#url"] != "target" {
+		f.t.Errorf("unexpected GetItem input %+v", in)
+	}
+	path := in.Key["slug"].(*types.AttributeValueMemberS).Value
+	url, ok := f.items[path]
+	if !ok {
+		return &dynamodb.GetItemOutput{}, nil
+	}
+	return &dynamodb.GetItemOutput{Item: map[string]types.AttributeValue{
+		"target": &types.AttributeValueMemberS{Value: url},
+	}}, nil
+}
+
+// get sends a GET for target to h and returns the recorded
+// response.
+func get(h http.Handler, target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+	return w
+}
+
+func TestStore(t *testing.T) {
+	client := fakeDynamoDB{t: t, items: map[string]string{"/a": "https://a.com"}}
+	h := urlshort.StoreHandler(NewStore(client, "links", "slug", "target"), http.NotFoundHandler())
+	if w := get(h, "/a"); w.Code != http.StatusFound || w.Header().Get("Location") != "https://a.com" {
+		t.Errorf("GET /a = %d %q, want 302 https://a.com", w.Code, w.Header().Get("Location"))
+	}
+	if w := get(h, "/b"); w.Code != http.StatusNotFound {
+		t.Errorf("GET /b = %d, want 404", w.Code)
+	}
+
+	failing := fakeDynamoDB{t: t, err: errors.New("throttled")}
+	h = urlshort.StoreHandler(NewStore(failing, "links", "slug", "target"), http.NotFoundHandler())
+	if w := get(h, "/a"); w.Code != http.StatusNotFound {
+		t.Errorf("GET /a with a failing table = %d, want 404", w.Code)
+	}
+}
+
+func TestHandlerNilClient(t *testing.T) {
+	if _, err := Handler(nil, "", "", "", http.NotFoundHandler()); err == nil {
+		t.Error("expected an error for a nil client")
+	}
+}
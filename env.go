@@ -0,0 +1,44 @@
+package urlshort
+
+import (
+	"net/http"
+	"os"
+	"strings"
+)
+
+// EnvHandler builds a MapHandler from the environment variables whose names start with
+// prefix, such as "URLSHORT_". See ParseEnv for how variable names map to paths.
+func EnvHandler(prefix string, fallback http.Handler) http.HandlerFunc {
+	return MapHandler(ParseEnv(os.Environ(), prefix), fallback)
+}
+
+// ParseEnv returns the redirects defined by the variables in
+// environ, which is in the "key=value" form of os.Environ, whose
+// names start with prefix. The value of a variable is its url,
+// and its path is the rest of its name after the prefix, with a
+// slash in front and each underscore turned into a slash. A
+// doubled underscore stands for a literal one. With the prefix
+// "URLSHORT_":
+//
+//     URLSHORT_promo=https://www.some-url.com/promo     /promo
+//     URLSHORT_docs_api=https://www.some-url.com/api    /docs/api
+//     URLSHORT_sign__up=https://www.some-url.com/join   /sign_up
+func ParseEnv(environ []string, prefix string) map[string]string {
+	pathsToUrls := make(map[string]string)
+	for _, kv := range environ {
+		i := strings.Index(kv, "=")
+		if i < 0 {
+			continue
+		}
+		name, url := kv[:i], kv[i+1:]
+		if !strings.HasPrefix(name, prefix) || len(name) == len(prefix) {
+			continue
+		}
+		parts := strings.Split(strings.TrimPrefix(name, prefix), "__")
+		for i, part := range parts {
+			parts[i] = strings.Replace(part, "_", "/", -1)
+		}
+		pathsToUrls["/"+strings.Join(parts, "_")] = url
+	}
+	return pathsToUrls
+}
@@ -0,0 +1,37 @@
+package urlshort
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestParseEnv(t *testing.T) {
+	m := ParseEnv([]string{
+		"URLSHORT_promo=https://p.com/?a=b",
+		"URLSHORT_docs_api=https://d.com",
+		"URLSHORT_sign__up=https://s.com",
+		"URLSHORT_=x",
+		"HOME=/root",
+		"junk",
+	}, "URLSHORT_")
+	want := map[string]string{
+		"/promo":    "https://p.com/?a=b",
+		"/docs/api": "https://d.com",
+		"/sign_up":  "https://s.com",
+	}
+	if len(m) != len(want) {
+		t.Fatalf("ParseEnv = %v, want %v", m, want)
+	}
+	for path, url := range want {
+		if m[path] != url {
+			t.Errorf("ParseEnv[%s] = %q, want %q", path, m[path], url)
+		}
+	}
+}
+
+func TestEnvHandler(t *testing.T) {
+	t.Setenv("URLSHORTTEST_x", "https://x.com")
+	h := EnvHandler("URLSHORTTEST_", notFound)
+	expectRedirect(t, h, "/x", http.StatusFound, "https://x.com")
+	expectStatus(t, h, "/y", http.StatusNotFound)
+}
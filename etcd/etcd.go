@@ -0,0 +1,171 @@
+// Package etcd serves redirects stored as keys under a prefix in
+// etcd.
+package etcd
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bcpoole/urlshort"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// retryDelay is how long a Store waits before retrying a failed
+// reload of its prefix.
+var retryDelay = 5 * time.Second
+
+// Handler loads every key under prefix from etcd and redirects the path named by the rest of
+// the key to the url stored as its value, so the key prefix + "/promo" redirects /promo. Else
+// falls back to provided Handler. The mapping is kept up to date as described for Store. The
+// returned function stops watching; use NewStore instead to also check the health of the
+// mapping with urlshort.HealthHandler.
+func Handler(client *clientv3.Client, prefix string, fallback http.Handler) (http.HandlerFunc, func() error, error) {
+	store, err := NewStore(client, prefix)
+	if err != nil {
+		return nil, nil, err
+	}
+	return urlshort.StoreHandler(store, fallback), store.Close, nil
+}
+
+// Store is a urlshort.Store holding a copy of the keys under a
+// prefix in etcd. The copy is kept up to date by watching the
+// prefix in the background: new and changed keys take effect
+// immediately and deleted keys are removed. If the watch is
+// cancelled by the server, the keys are loaded again, retrying
+// until etcd answers; meanwhile the last keys loaded keep being
+// served and Ping reports the failure.
+type Store struct {
+	prefix string
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu    sync.RWMutex
+	paths map[string]string
+	err   error
+}
+
+// NewStore loads the keys under prefix from etcd and returns a
+// Store serving them, which watches the prefix until it is closed.
+func NewStore(client *clientv3.Client, prefix string) (*Store, error) {
+	if client == nil {
+		return nil, errors.New("etcd client is nil")
+	}
+	return newStore(client, client, prefix)
+}
+
+func newStore(kv clientv3.KV, watcher clientv3.Watcher, prefix string) (*Store, error) {
+	s := &Store{prefix: prefix, done: make(chan struct{})}
+	rev, err := s.load(context.Background(), kv)
+	if err != nil {
+		return nil, err
+	}
+	var ctx context.Context
+	ctx, s.cancel = context.WithCancel(context.Background())
+	go s.run(ctx, kv, watcher, rev)
+	return s, nil
+}
+
+// run watches the prefix from revision rev until ctx is done.
+func (s *Store) run(ctx context.Context, kv clientv3.KV, watcher clientv3.Watcher, rev int64) {
+	defer close(s.done)
+	for {
+		rev = s.watch(ctx, watcher, rev)
+		// The watch was cancelled by the server, for instance
+		// because the revision it resumed from was compacted.
+		// Start over from a fresh copy of the keys.
+		for {
+			if ctx.Err() != nil {
+				return
+			}
+			next, err := s.load(ctx, kv)
+			if ctx.Err() != nil {
+				return
+			}
+			s.mu.Lock()
+			s.err = err
+			s.mu.Unlock()
+			if err == nil {
+				rev = next
+				break
+			}
+			log.Printf("urlshort: reload etcd prefix %s: %v", s.prefix, err)
+			select {
+			case <-time.After(retryDelay):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// Lookup returns the url of the key for path.
+func (s *Store) Lookup(path string) (string, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	url, ok := s.paths[path]
+	return url, ok, nil
+}
+
+// Ping reports the error of the last attempt to reload the keys if
+// it failed, in which case the keys served may be out of date.
+func (s *Store) Ping(ctx context.Context) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.err
+}
+
+// Close stops watching the prefix. The keys loaded last keep
+// being served.
+func (s *Store) Close() error {
+	s.cancel()
+	<-s.done
+	return nil
+}
+
+// load replaces the mapping with the keys currently under the
+// prefix and returns the revision they were read at.
+func (s *Store) load(ctx context.Context, kv clientv3.KV) (int64, error) {
+	resp, err := kv.Get(ctx, s.prefix, clientv3.WithPrefix())
+	if err != nil {
+		return 0, err
+	}
+	paths := make(map[string]string, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		paths[strings.TrimPrefix(string(kv.Key), s.prefix)] = string(kv.Value)
+	}
+	s.mu.Lock()
+	s.paths = paths
+	s.mu.Unlock()
+	return resp.Header.Revision, nil
+}
+
+// watch applies the changes made to the prefix after revision rev
+// until the watch ends, and returns the last revision applied.
+func (s *Store) watch(ctx context.Context, watcher clientv3.Watcher, rev int64) int64 {
+	changes := watcher.Watch(ctx, s.prefix, clientv3.WithPrefix(), clientv3.WithRev(rev+1))
+	for resp := range changes {
+		if err := resp.Err(); err != nil {
+			log.Printf("urlshort: watch etcd prefix %s: %v", s.prefix, err)
+			continue
+		}
+		s.mu.Lock()
+		for _, event := range resp.Events {
+			path := strings.TrimPrefix(string(event.Kv.Key), s.prefix)
+			switch event.Type {
+			case mvccpb.PUT:
+				s.paths[path] = string(event.Kv.Value)
+			case mvccpb.DELETE:
+				delete(s.paths, path)
+			}
+		}
+		s.mu.Unlock()
+		rev = resp.Header.Revision
+	}
+	return rev
+}
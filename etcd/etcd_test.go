@@ -0,0 +1,176 @@
+package etcd
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bcpoole/urlshort"
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// fakeKV answers every Get with a single redirect from /a to url,
+// or with err if it is set.
+type fakeKV struct {
+	clientv3.KV
+
+	mu  sync.Mutex
+	url string
+	err error
+}
+
+func (f *fakeKV) set(url string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.url, f.err = url, err
+}
+
+func (f *fakeKV) Get(ctx context.Context, key string, _ ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &clientv3.GetResponse{
+		Header: &etcdserverpb.ResponseHeader{Revision: 5},
+		Kvs:    []*mvccpb.KeyValue{{Key: []byte(key + "/a"), Value: []byte(f.url)}},
+	}, nil
+}
+
+// fakeWatcher sends the responses written to events to its
+// watchers, and reports the revision each watch starts at on rev.
+// Sending on end ends the current watch, as the server does when
+// the revision it resumed from was compacted.
+type fakeWatcher struct {
+	clientv3.Watcher
+	events chan clientv3.WatchResponse
+	end    chan struct{}
+	rev    chan int64
+}
+
+func newFakeWatcher() fakeWatcher {
+	return fakeWatcher{
+		events: make(chan clientv3.WatchResponse),
+		end:    make(chan struct{}),
+		rev:    make(chan int64, 10),
+	}
+}
+
+func (f fakeWatcher) Watch(ctx context.Context, key string, opts ...clientv3.OpOption) clientv3.WatchChan {
+	f.rev <- clientv3.OpGet(key, opts...).Rev()
+	out := make(chan clientv3.WatchResponse)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case r := <-f.events:
+				out <- r
+			case <-f.end:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// eventually retries check until it returns true or a few seconds
+// have passed.
+func eventually(check func() bool) bool {
+	deadline := time.Now().Add(5 * time.Second)
+	for !check() {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return true
+}
+
+// status returns the status of a GET for target sent to h.
+func status(h http.Handler, target string) int {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+	return w.Code
+}
+
+func TestStore(t *testing.T) {
+	w := newFakeWatcher()
+	s, err := newStore(&fakeKV{url: "https://a.com"}, w, "/links")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if rev := <-w.rev; rev != 6 {
+		t.Errorf("watch started at revision %d, want 6", rev)
+	}
+	if u, ok, _ := s.Lookup("/a"); !ok || u != "https://a.com" {
+		t.Errorf("Lookup(/a) = %q, %v", u, ok)
+	}
+
+	w.events <- clientv3.WatchResponse{
+		Header: &etcdserverpb.ResponseHeader{Revision: 7},
+		Events: []*clientv3.Event{
+			{Type: mvccpb.PUT, Kv: &mvccpb.KeyValue{Key: []byte("/links/b"), Value: []byte("https://b.com")}},
+			{Type: mvccpb.DELETE, Kv: &mvccpb.KeyValue{Key: []byte("/links/a")}},
+		},
+	}
+	h := urlshort.StoreHandler(s, http.NotFoundHandler())
+	if !eventually(func() bool { return status(h, "/b") == http.StatusFound && status(h, "/a") == http.StatusNotFound }) {
+		t.Fatal("watch events were not applied")
+	}
+}
+
+func TestStoreReloadRetry(t *testing.T) {
+	defer func(d time.Duration) { retryDelay = d }(retryDelay)
+	retryDelay = 10 * time.Millisecond
+
+	kv := &fakeKV{url: "https://a.com"}
+	w := newFakeWatcher()
+	s, err := newStore(kv, w, "/links")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	<-w.rev
+
+	// A failed reload keeps the last keys and is reported by Ping
+	// until a retry succeeds.
+	kv.set("", errors.New("unavailable"))
+	w.end <- struct{}{}
+	if !eventually(func() bool { return s.Ping(context.Background()) != nil }) {
+		t.Fatal("Ping did not report the failed reload")
+	}
+	if u, _, _ := s.Lookup("/a"); u != "https://a.com" {
+		t.Errorf("Lookup(/a) = %q during failed reloads, want https://a.com", u)
+	}
+
+	kv.set("https://new.com", nil)
+	if !eventually(func() bool { return s.Ping(context.Background()) == nil }) {
+		t.Fatal("Ping still failing after etcd came back")
+	}
+	if u, _, _ := s.Lookup("/a"); u != "https://new.com" {
+		t.Errorf("Lookup(/a) = %q after the reload, want https://new.com", u)
+	}
+	select {
+	case rev := <-w.rev:
+		if rev != 6 {
+			t.Errorf("watch restarted at revision %d, want 6", rev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("watch was not restarted")
+	}
+}
+
+func TestHandlerNilClient(t *testing.T) {
+	if _, _, err := Handler(nil, "/links", http.NotFoundHandler()); err == nil {
+		t.Error("expected an error for a nil client")
+	}
+}
@@ -0,0 +1,142 @@
+package urlshort
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+
+	"github.com/BurntSushi/toml"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// ExportYAML encodes paths as a YAML list of path/url entries,
+// sorted by path, in the format read by YAMLHandler and
+// ParseYAML.
+func ExportYAML(paths map[string]string) ([]byte, error) {
+	return yaml.Marshal(mapEntries(paths))
+}
+
+// ExportJSON encodes paths as a JSON array of path/url objects,
+// sorted by path, in the format read by JSONHandler and
+// ParseJSON.
+func ExportJSON(paths map[string]string) ([]byte, error) {
+	data, err := json.MarshalIndent(mapEntries(paths), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// ExportCSV encodes paths as CSV with a path,url header row,
+// sorted by path, in the format read by CSVHandler and ParseCSV.
+func ExportCSV(paths map[string]string) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"path", "url"})
+	for _, e := range mapEntries(paths) {
+		w.Write([]string{e.Path, e.URL})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ExportTOML encodes paths as a TOML array of redirects tables,
+// sorted by path, in the format read by TOMLHandler.
+func ExportTOML(paths map[string]string) ([]byte, error) {
+	var buf bytes.Buffer
+	doc := struct {
+		Redirects []entry `toml:"redirects"`
+	}{mapEntries(paths)}
+	if err := toml.NewEncoder(&buf).Encode(doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Format is a mapping document format supported by Convert.
+type Format int
+
+// The formats supported by Convert.
+const (
+	FormatYAML Format = iota
+	FormatJSON
+	FormatCSV
+	FormatTOML
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatYAML:
+		return "yaml"
+	case FormatJSON:
+		return "json"
+	case FormatCSV:
+		return "csv"
+	case FormatTOML:
+		return "toml"
+	}
+	return fmt.Sprintf("Format(%d)", int(f))
+}
+
+// Convert parses data as a mapping document in the from format
+// and exports it in the to format. Like the strict parsers, it
+// returns a *DuplicatePathError if a path is defined more than
+// once rather than silently dropping a mapping.
+func Convert(data []byte, from, to Format) ([]byte, error) {
+	entries, err := decodeEntries(data, from)
+	if err != nil {
+		return nil, err
+	}
+	paths, err := buildRedirectMapStrict(entries)
+	if err != nil {
+		return nil, err
+	}
+
+	switch to {
+	case FormatYAML:
+		return ExportYAML(paths)
+	case FormatJSON:
+		return ExportJSON(paths)
+	case FormatCSV:
+		return ExportCSV(paths)
+	case FormatTOML:
+		return ExportTOML(paths)
+	}
+	return nil, fmt.Errorf("unsupported format: %s", to)
+}
+
+// decodeEntries decodes the entries of a mapping document in the
+// given format.
+func decodeEntries(data []byte, format Format) ([]entry, error) {
+	return decodeEntriesStrict(data, format, false)
+}
+
+// decodeEntriesStrict behaves like decodeEntries but, if strict is
+// set, fails on fields entries do not have. CSV documents have no
+// such fields, as columns are matched by name.
+func decodeEntriesStrict(data []byte, format Format, strict bool) ([]entry, error) {
+	switch format {
+	case FormatYAML:
+		return decodeYAMLEntriesStrict(bytes.NewReader(data), strict)
+	case FormatJSON:
+		if strict {
+			return decodeJSONEntriesStrict(bytes.NewReader(data))
+		}
+		return decodeJSONEntries(bytes.NewReader(data))
+	case FormatCSV:
+		return parseCSVEntries(data)
+	case FormatTOML:
+		return parseTOMLEntriesStrict(data, strict)
+	}
+	return nil, fmt.Errorf("unsupported format: %s", format)
+}
+
+// ConvertYAMLToJSON converts a YAML mapping document to JSON. See
+// Convert.
+func ConvertYAMLToJSON(yml []byte) ([]byte, error) {
+	return Convert(yml, FormatYAML, FormatJSON)
+}
@@ -0,0 +1,75 @@
+package urlshort
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestExport(t *testing.T) {
+	m := map[string]string{"/b": "https://b.com", "/a": "https://a.com?x=1"}
+	y, err := ExportYAML(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "- path: /a\n  url: https://a.com?x=1\n- path: /b\n  url: https://b.com\n"; string(y) != want {
+		t.Errorf("ExportYAML = %q, want %q", y, want)
+	}
+	j, err := ExportJSON(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name  string
+		data  []byte
+		parse func([]byte) (map[string]string, error)
+	}{
+		{"YAML", y, ParseYAML},
+		{"JSON", j, ParseJSON},
+	} {
+		got, err := tc.parse(tc.data)
+		if err != nil || len(got) != 2 || got["/a"] != m["/a"] || got["/b"] != m["/b"] {
+			t.Errorf("%s round trip = %v, %v", tc.name, got, err)
+		}
+	}
+
+	if empty, _ := ExportJSON(nil); strings.TrimSpace(string(empty)) != "[]" {
+		t.Errorf("ExportJSON(nil) = %s, want []", empty)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	m := map[string]string{"/b": "https://b.com", "/a": "https://a.com?x=1,2"}
+	parse := map[Format]func([]byte) (map[string]string, error){
+		FormatYAML: ParseYAML,
+		FormatJSON: ParseJSON,
+		FormatCSV:  ParseCSV,
+		FormatTOML: ParseTOML,
+	}
+	src := map[Format][]byte{}
+	src[FormatYAML], _ = ExportYAML(m)
+	src[FormatJSON], _ = ExportJSON(m)
+	src[FormatCSV], _ = ExportCSV(m)
+	src[FormatTOML], _ = ExportTOML(m)
+
+	for from := range parse {
+		for to := range parse {
+			out, err := Convert(src[from], from, to)
+			if err != nil {
+				t.Errorf("Convert %v to %v: %v", from, to, err)
+				continue
+			}
+			got, err := parse[to](out)
+			if err != nil || len(got) != 2 || got["/a"] != m["/a"] {
+				t.Errorf("Convert %v to %v = %v, %v", from, to, got, err)
+			}
+		}
+	}
+
+	_, err := ConvertYAMLToJSON([]byte("- {path: /a, url: x}\n- {path: /a, url: y}\n"))
+	var dup *DuplicatePathError
+	if !errors.As(err, &dup) {
+		t.Errorf("ConvertYAMLToJSON error = %v, want a *DuplicatePathError", err)
+	}
+}
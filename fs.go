@@ -0,0 +1,28 @@
+package urlshort
+
+import (
+	"fmt"
+	"io/fs"
+	"net/http"
+)
+
+// FSHandler reads the mapping document name from fsys, such as an embed.FS compiled into the
+// binary, and redirects based on its entries in the given format. Else falls back to provided
+// Handler. An error is returned if the file cannot be read or parsed.
+func FSHandler(fsys fs.FS, name string, format Format, fallback http.Handler) (http.HandlerFunc, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case FormatYAML:
+		return YAMLHandler(data, fallback)
+	case FormatJSON:
+		return JSONHandler(data, fallback)
+	case FormatTOML:
+		return TOMLHandler(data, fallback)
+	case FormatCSV:
+		return CSVHandler(data, fallback)
+	}
+	return nil, fmt.Errorf("unsupported format for %s: %s", name, format)
+}
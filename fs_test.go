@@ -0,0 +1,44 @@
+package urlshort
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFSHandler(t *testing.T) {
+	fsys := fstest.MapFS{
+		"links/a.yml":  {Data: []byte("- path: /y\n  url: https://y.com\n")},
+		"links/b.json": {Data: []byte(`[{"path": "/j", "url": "https://j.com"}]`)},
+		"links/c.txt":  {Data: []byte(`[{"path": "/t", "url": "https://t.com"}]`)},
+		"links/d.toml": {Data: []byte("[[redirects]]\npath = \"/m\"\nurl = \"https://m.com\"\n")},
+		"links/e.csv":  {Data: []byte("path,url\n/c,https://c.com\n")},
+	}
+	for _, tc := range []struct {
+		file       string
+		format     Format
+		path, want string
+	}{
+		{"links/a.yml", FormatYAML, "/y", "https://y.com"},
+		{"links/b.json", FormatJSON, "/j", "https://j.com"},
+		{"links/c.txt", FormatJSON, "/t", "https://t.com"},
+		{"links/d.toml", FormatTOML, "/m", "https://m.com"},
+		{"links/e.csv", FormatCSV, "/c", "https://c.com"},
+	} {
+		h, err := FSHandler(fsys, tc.file, tc.format, notFound)
+		if err != nil {
+			t.Errorf("%s: %v", tc.file, err)
+			continue
+		}
+		expectRedirect(t, h, tc.path, http.StatusFound, tc.want)
+	}
+
+	if _, err := FSHandler(fsys, "links/c.txt", Format(-1), notFound); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+	if _, err := FSHandler(fsys, "missing.yaml", FormatYAML, notFound); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("missing file error = %v, want fs.ErrNotExist", err)
+	}
+}
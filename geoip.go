@@ -0,0 +1,69 @@
+package urlshort
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// CountryResolver resolves an IP address to the ISO code of the
+// country it is located in, and reports whether that country is
+// a member of the European Union. The geoip package provides one
+// reading a MaxMind database.
+type CountryResolver interface {
+	Country(ip net.IP) (isoCode string, inEU bool, err error)
+}
+
+// euCountry is the key of per-country targets matching any
+// member of the European Union.
+const euCountry = "EU"
+
+// geoLookup picks per-country targets for requests.
+type geoLookup struct {
+	resolver          CountryResolver
+	trustForwardedFor bool
+}
+
+// target returns the target in countries for the country of the
+// client of r. A country code takes precedence over EU. Requests
+// whose address cannot be resolved get no target.
+func (g geoLookup) target(r *http.Request, countries map[string]string) (string, bool) {
+	if g.resolver == nil {
+		return "", false
+	}
+	ip := clientIP(r, g.trustForwardedFor)
+	if ip == nil {
+		return "", false
+	}
+	code, inEU, err := g.resolver.Country(ip)
+	if err != nil {
+		log.Printf("urlshort: resolve country of %s: %v", ip, err)
+		return "", false
+	}
+	if target, ok := countries[strings.ToUpper(code)]; ok {
+		return target, true
+	}
+	if target, ok := countries[euCountry]; ok && inEU {
+		return target, true
+	}
+	return "", false
+}
+
+// clientIP returns the address of the client of r, or nil if it
+// is unknown. With trustForwardedFor, the last address in the
+// X-Forwarded-For header is used, which is the one added by the
+// proxy in front of the handler.
+func clientIP(r *http.Request, trustForwardedFor bool) net.IP {
+	if trustForwardedFor {
+		if header := r.Header.Get("X-Forwarded-For"); header != "" {
+			hops := strings.Split(header, ",")
+			return net.ParseIP(strings.TrimSpace(hops[len(hops)-1]))
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
@@ -0,0 +1,29 @@
+// Package geoip resolves the countries of clients for the
+// per-country targets of urlshort entries from a MaxMind database.
+package geoip
+
+import (
+	"net"
+
+	"github.com/bcpoole/urlshort"
+	"github.com/oschwald/geoip2-golang"
+)
+
+// NewResolver returns a urlshort.CountryResolver reading from a
+// MaxMind GeoIP2 or GeoLite2 country database, to be set as the
+// GeoIP of urlshort.Options.
+func NewResolver(db *geoip2.Reader) urlshort.CountryResolver {
+	return resolver{db}
+}
+
+type resolver struct {
+	db *geoip2.Reader
+}
+
+func (r resolver) Country(ip net.IP) (string, bool, error) {
+	record, err := r.db.Country(ip)
+	if err != nil {
+		return "", false, err
+	}
+	return record.Country.IsoCode, record.Country.IsInEuropeanUnion, nil
+}
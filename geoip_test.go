@@ -0,0 +1,61 @@
+package urlshort
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeGeo is a CountryResolver mapping IP addresses to a country
+// code and whether it is in the EU.
+type fakeGeo map[string]struct {
+	country string
+	eu      bool
+}
+
+func (f fakeGeo) Country(ip net.IP) (string, bool, error) {
+	c, ok := f[ip.String()]
+	if !ok {
+		return "", false, errors.New("address not found")
+	}
+	return c.country, c.eu, nil
+}
+
+func TestCountryTargets(t *testing.T) {
+	doc := []byte("- path: /l\n  url: https://global.com\n  countries:\n    eu: https://gdpr.com\n    ch: https://ch.com\n")
+	if _, err := YAMLHandler(doc, notFound); err == nil {
+		t.Fatal("expected an error for country targets without a GeoIP resolver")
+	}
+
+	geo := fakeGeo{
+		"1.1.1.1": {"DE", true},
+		"2.2.2.2": {"US", false},
+		"3.3.3.3": {"CH", false},
+	}
+	h, err := YAMLHandlerWithOptions(doc, notFound, Options{GeoIP: geo, TrustForwardedFor: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		remote, forwarded, want string
+	}{
+		{"1.1.1.1:5", "", "https://gdpr.com"},
+		{"9.9.9.9:5", "8.8.8.8, 3.3.3.3", "https://ch.com"},
+		{"2.2.2.2:5", "", "https://global.com"},
+		{"garbage", "", "https://global.com"},
+		{"4.4.4.4:1", "", "https://global.com"},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/l", nil)
+		r.RemoteAddr = tc.remote
+		if tc.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", tc.forwarded)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if got := w.Header().Get("Location"); got != tc.want {
+			t.Errorf("from %s (forwarded %q): Location = %q, want %q", tc.remote, tc.forwarded, got, tc.want)
+		}
+	}
+}
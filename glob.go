@@ -0,0 +1,93 @@
+package urlshort
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// globPattern is an entry path with wildcards. See Options.Globs.
+type globPattern struct {
+	path     string
+	segments []string
+}
+
+// isGlob reports whether path has wildcards.
+func isGlob(path string) bool {
+	return strings.Contains(path, "*")
+}
+
+// parseGlob parses path as a glob pattern. A ** must make up a
+// whole segment.
+func parseGlob(path string) (globPattern, error) {
+	g := globPattern{path: path, segments: strings.Split(path, "/")}
+	for _, segment := range g.segments {
+		if segment != "**" && strings.Contains(segment, "**") {
+			return g, fmt.Errorf("glob %s: ** must be a whole segment", path)
+		}
+	}
+	return g, nil
+}
+
+// sortGlobs orders globs so that longer patterns come first,
+// breaking ties by pattern.
+func sortGlobs(globs []globPattern) {
+	sort.Slice(globs, func(i, j int) bool {
+		if len(globs[i].path) != len(globs[j].path) {
+			return len(globs[i].path) > len(globs[j].path)
+		}
+		return globs[i].path < globs[j].path
+	})
+}
+
+// match reports whether path matches g.
+func (g globPattern) match(path string) bool {
+	return matchGlobSegments(g.segments, strings.Split(path, "/"))
+}
+
+// matchGlobSegments reports whether the path segments match the
+// pattern segments, where a ** matches one or more non-empty
+// segments.
+func matchGlobSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 1; i <= len(segments) && segments[i-1] != ""; i++ {
+				if matchGlobSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 || !matchGlobSegment(pattern[0], segments[0]) {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
+
+// matchGlobSegment reports whether segment matches pattern, where
+// a * matches any run of characters. A pattern with wildcards
+// never matches an empty segment.
+func matchGlobSegment(pattern, segment string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == segment
+	}
+	if segment == "" {
+		return false
+	}
+	last := parts[len(parts)-1]
+	if !strings.HasPrefix(segment, parts[0]) || !strings.HasSuffix(segment[len(parts[0]):], last) {
+		return false
+	}
+	segment = segment[len(parts[0]) : len(segment)-len(last)]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(segment, part)
+		if i < 0 {
+			return false
+		}
+		segment = segment[i+len(part):]
+	}
+	return true
+}
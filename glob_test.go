@@ -0,0 +1,55 @@
+package urlshort
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestGlobs(t *testing.T) {
+	doc := `
+- path: /blog/*
+  url: https://blog.com/any
+- path: /blog/special
+  url: https://blog.com/special
+- path: /docs/**
+  url: https://docs.com/deep
+- path: /docs/api/**
+  url: https://docs.com/api
+- path: /img/*.png
+  url: https://img.com/png
+`
+	h, err := YAMLHandlerWithOptions([]byte(doc), notFound, Options{Globs: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]string{
+		"/blog/post":     "https://blog.com/any",
+		"/blog/special":  "https://blog.com/special",
+		"/docs/a":        "https://docs.com/deep",
+		"/docs/a/b/c":    "https://docs.com/deep",
+		"/docs/api/v1/x": "https://docs.com/api",
+		"/img/cat.png":   "https://img.com/png",
+	} {
+		expectRedirect(t, h, path, http.StatusFound, want)
+	}
+	for _, path := range []string{"/blog/a/b", "/blog/", "/blog", "/docs", "/docs/", "/img/cat.jpg", "/img/.png/x"} {
+		expectStatus(t, h, path, http.StatusNotFound)
+	}
+
+	if _, err := YAMLHandlerWithOptions([]byte("- path: /a**\n  url: https://a.com\n"), notFound, Options{Globs: true}); err == nil {
+		t.Error("expected an error for ** within a segment")
+	}
+}
+
+func TestGlobsExactFirst(t *testing.T) {
+	doc := `[{"path": "/x/*", "url": "https://x.com"}, {"path": "/x/y", "url": "https://y.com"}]`
+	h, err := JSONHandlerWithOptions([]byte(doc), notFound, Options{Globs: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectRedirect(t, h, "/x/y", http.StatusFound, "https://y.com")
+	expectRedirect(t, h, "/x/z", http.StatusFound, "https://x.com")
+
+	h, _ = JSONHandlerWithOptions([]byte(doc), notFound, Options{})
+	expectStatus(t, h, "/x/z", http.StatusNotFound)
+}
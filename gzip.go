@@ -0,0 +1,126 @@
+package urlshort
+
+import (
+	"compress/gzip"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// GzipHandler will return an http.HandlerFunc that serves next,
+// compressing its response with gzip when the client accepts it,
+// which shrinks the large JSON bodies of handlers such as
+// AdminHandler and SearchHandler. Redirects and other responses
+// without a meaningful body are sent unchanged, as are responses
+// that already have a Content-Encoding or whose Content-Type is
+// compressed already, such as images and archives.
+func GzipHandler(next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if r.Method == http.MethodHead || !acceptsGzip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		gw := &gzipWriter{ResponseWriter: w}
+		defer gw.close()
+		next.ServeHTTP(gw, r)
+	}
+}
+
+// acceptsGzip reports whether the Accept-Encoding header of r
+// allows gzip.
+func acceptsGzip(r *http.Request) bool {
+	for _, accept := range r.Header.Values("Accept-Encoding") {
+		for _, part := range strings.Split(accept, ",") {
+			fields := strings.Split(part, ";")
+			if !strings.EqualFold(strings.TrimSpace(fields[0]), "gzip") {
+				continue
+			}
+			q := 1.0
+			for _, param := range fields[1:] {
+				if v := strings.TrimSpace(param); strings.HasPrefix(v, "q=") {
+					var err error
+					if q, err = strconv.ParseFloat(v[2:], 64); err != nil {
+						q = 0
+					}
+				}
+			}
+			return q > 0
+		}
+	}
+	return false
+}
+
+// gzipWriter is an http.ResponseWriter that compresses the body
+// of the response if it is worth compressing, which it decides
+// once the status code is known.
+type gzipWriter struct {
+	http.ResponseWriter
+	gz          *gzip.Writer
+	wroteHeader bool
+}
+
+func (w *gzipWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	if compressible(status, w.Header()) {
+		w.Header().Del("Content-Length")
+		w.Header().Set("Content-Encoding", "gzip")
+		w.gz = gzip.NewWriter(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *gzipWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		// Compressed bodies cannot be sniffed, so the type is
+		// detected from the uncompressed one first.
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.gz == nil {
+		return w.ResponseWriter.Write(b)
+	}
+	return w.gz.Write(b)
+}
+
+// close flushes the compressed body, if any.
+func (w *gzipWriter) close() {
+	if w.gz != nil {
+		w.gz.Close()
+	}
+}
+
+// compressible reports whether a response with status and header
+// should be compressed.
+func compressible(status int, header http.Header) bool {
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified ||
+		(status >= 300 && status < 400) {
+		return false
+	}
+	if header.Get("Content-Encoding") != "" {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		return true
+	}
+	switch {
+	case mediaType == "image/svg+xml":
+		return true
+	case strings.HasPrefix(mediaType, "image/"), strings.HasPrefix(mediaType, "video/"),
+		strings.HasPrefix(mediaType, "audio/"), strings.HasPrefix(mediaType, "font/woff"):
+		return false
+	}
+	switch mediaType {
+	case "application/gzip", "application/x-gzip", "application/zip", "application/zstd",
+		"application/x-bzip2", "application/x-7z-compressed", "application/x-rar-compressed":
+		return false
+	}
+	return true
+}
@@ -0,0 +1,70 @@
+package urlshort
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestGzipHandler(t *testing.T) {
+	big := strings.Repeat(`{"path": "/a", "url": "https://a.com"}`, 100)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/json":
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Content-Length", strconv.Itoa(len(big)))
+			w.Write([]byte(big))
+		case "/png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte(big))
+		case "/plain":
+			w.Write([]byte(big))
+		default:
+			http.Redirect(w, r, "https://a.com", http.StatusFound)
+		}
+	})
+	h := GzipHandler(next)
+	get := func(path, encoding string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		if encoding != "" {
+			r.Header.Set("Accept-Encoding", encoding)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	w := get("/json", "gzip, deflate")
+	if w.Header().Get("Content-Encoding") != "gzip" || w.Header().Get("Content-Length") != "" {
+		t.Fatalf("gzipped headers = %v", w.Header())
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(zr)
+	if err != nil || string(body) != big {
+		t.Errorf("decompressed body differs: %v", err)
+	}
+
+	if w := get("/json", ""); w.Header().Get("Content-Encoding") != "" || w.Body.String() != big {
+		t.Error("compressed a response for a client without gzip")
+	}
+	if w := get("/json", "gzip;q=0"); w.Header().Get("Content-Encoding") != "" {
+		t.Error("compressed a response for a client refusing gzip")
+	}
+	if w := get("/png", "gzip"); w.Header().Get("Content-Encoding") != "" {
+		t.Error("compressed an image")
+	}
+	if w := get("/r", "gzip"); w.Code != http.StatusFound || w.Header().Get("Content-Encoding") != "" {
+		t.Errorf("redirect = %d %v, want an uncompressed 302", w.Code, w.Header())
+	}
+	w = get("/plain", "gzip")
+	if w.Header().Get("Content-Encoding") != "gzip" || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+		t.Errorf("sniffed body headers = %v", w.Header())
+	}
+}
@@ -1,14 +1,13 @@
 package urlshort
 
 import (
-	"encoding/json"
+	"bytes"
 	"fmt"
-	"log"
+	"io"
 	"net/http"
-	"time"
-
-	"github.com/boltdb/bolt"
-	yaml "gopkg.in/yaml.v2"
+	"net/url"
+	"sort"
+	"strings"
 )
 
 // MapHandler will return an http.HandlerFunc (which also
@@ -17,15 +16,31 @@ import (
 // that each key in the map points to, in string format).
 // If the path is not provided in the map, then the fallback
 // http.Handler will be called instead.
+//
+// HEAD requests for a path are answered with the same status and
+// Location header as GET requests, but without a body. This holds
+// for every handler in this package.
 func MapHandler(pathsToUrls map[string]string, fallback http.Handler) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		path, ok := pathsToUrls[r.URL.Path]
-		if ok {
-			http.Redirect(w, r, path, http.StatusFound)
-		} else {
-			fallback.ServeHTTP(w, r)
-		}
+	handler, _ := MapHandlerWithStatus(pathsToUrls, fallback, http.StatusFound)
+	return handler
+}
+
+// MapHandlerWithStatus behaves like MapHandler but redirects
+// using the provided status code instead of http.StatusFound.
+// Only 301, 302, 303, 307 and 308 are accepted; any other
+// status results in an error.
+func MapHandlerWithStatus(pathsToUrls map[string]string, fallback http.Handler, status int) (http.HandlerFunc, error) {
+	opts, err := statusOptions(status)
+	if err != nil {
+		return nil, err
 	}
+	return MapHandlerWithOptions(pathsToUrls, fallback, opts)
+}
+
+// MapHandlerWithOptions behaves like MapHandler but responds to
+// matched paths as configured by opts.
+func MapHandlerWithOptions(pathsToUrls map[string]string, fallback http.Handler, opts Options) (http.HandlerFunc, error) {
+	return entriesHandler(mapEntries(pathsToUrls), fallback, opts)
 }
 
 // YAMLHandler will parse the provided YAML and then return
@@ -39,99 +54,292 @@ func MapHandler(pathsToUrls map[string]string, fallback http.Handler) http.Handl
 //     - path: /some-path
 //       url: https://www.some-url.com/demo
 //
+// or, alternatively, a mapping of paths to urls:
+//
+//     /some-path: https://www.some-url.com/demo
+//
+// Entries in the list format may list several paths, which all
+// redirect to the same url:
+//
+//     - paths: [/docs, /documentation, /help]
+//       url: https://www.some-url.com/docs
+//
+// Entries may also set expires to an RFC3339 timestamp, after
+// which the path is treated as missing:
+//
+//     - path: /spring-sale
+//       url: https://www.some-url.com/sale
+//       expires: 2018-06-01T00:00:00Z
+//
+// Likewise, active_from and active_until limit the path to a
+// window of time. Outside of it the path is treated as missing.
+//
+// Instead of a url, an entry may list several targets with
+// weights to split traffic between them:
+//
+//     - path: /signup
+//       targets:
+//         - url: https://www.some-url.com/signup-a
+//           weight: 70
+//         - url: https://www.some-url.com/signup-b
+//           weight: 30
+//
+// An entry may send mobile, tablet or desktop clients, as told
+// apart by their User-Agent header, to a different target than
+// the url, which remains the default:
+//
+//     - path: /app
+//       url: https://www.some-url.com/app
+//       devices:
+//         mobile: https://apps.some-url.com/app
+//
+// Similarly, an entry may map language tags to targets, which are
+// matched against the Accept-Language header of the request. A
+// header asking for fr-CA matches fr, and requests matching none
+// of the languages go to the url:
+//
+//     - path: /docs
+//       url: https://www.some-url.com/docs
+//       languages:
+//         de: https://www.some-url.com/de/docs
+//         fr: https://www.some-url.com/fr/docs
+//
+// Entries may also map ISO country codes, or EU for any member
+// of the European Union, to targets for clients in those
+// countries. This requires the GeoIP option; see Options.
+//
+// An entry may also set utm to a mapping of query parameters to
+// add to its target, such as utm_source and utm_medium.
+//
+// An entry may set status to redirect with a code other than the
+// one of the handler, such as 307 or 308 for links fronting POST
+// endpoints, which clients then re-send with the same method and
+// body. Entries may also set headers to add to their redirect
+// response:
+//
+//     - path: /report
+//       url: https://www.some-url.com/report
+//       headers:
+//         Referrer-Policy: no-referrer
+//
+// Setting disabled on an entry makes its path answer 410 Gone,
+// with disabled_message as the body if set, instead of redirecting
+// or falling back:
+//
+//     - path: /sale
+//       url: https://www.some-url.com/sale
+//       disabled: true
+//       disabled_message: The sale is paused.
+//
 // The only errors that can be returned all related to having
 // invalid YAML data.
 //
 // See MapHandler to create a similar http.HandlerFunc via
 // a mapping of paths to urls.
 func YAMLHandler(yml []byte, fallback http.Handler) (http.HandlerFunc, error) {
-	ymlPaths := []map[string]string{}
-	err := yaml.Unmarshal(yml, &ymlPaths)
+	return YAMLHandlerWithStatus(yml, fallback, http.StatusFound)
+}
+
+// YAMLHandlerWithStatus behaves like YAMLHandler but redirects
+// using the provided status code. See MapHandlerWithStatus for
+// the accepted codes.
+func YAMLHandlerWithStatus(yml []byte, fallback http.Handler, status int) (http.HandlerFunc, error) {
+	opts, err := statusOptions(status)
 	if err != nil {
 		return nil, err
 	}
-	paths := buildRedirectMap(ymlPaths)
+	return YAMLHandlerWithOptions(yml, fallback, opts)
+}
 
-	return func(w http.ResponseWriter, r *http.Request) {
-		path, ok := paths[r.URL.Path]
-		if ok {
-			http.Redirect(w, r, path, http.StatusFound)
-		} else {
-			fallback.ServeHTTP(w, r)
-		}
-	}, nil
+// YAMLHandlerWithOptions behaves like YAMLHandler but responds
+// to matched paths as configured by opts.
+func YAMLHandlerWithOptions(yml []byte, fallback http.Handler, opts Options) (http.HandlerFunc, error) {
+	entries, err := decodeYAMLEntriesStrict(bytes.NewReader(yml), opts.StrictFields)
+	if err != nil {
+		return nil, err
+	}
+
+	return entriesHandler(entries, fallback, opts)
 }
 
 // JSONHandler parses json []byte of url handler mappings an redirects base on those inputs.
-// Else falls back to provided Handler.
+// Else falls back to provided Handler. The mappings may be either an array of
+// {"path": ..., "url": ...} objects or a single {"/path": "url"} object.
 func JSONHandler(data []byte, fallback http.Handler) (http.HandlerFunc, error) {
-	jsonPaths := []map[string]string{}
-	err := json.Unmarshal(data, &jsonPaths)
+	return JSONHandlerWithStatus(data, fallback, http.StatusFound)
+}
+
+// JSONHandlerWithStatus behaves like JSONHandler but redirects using the provided status code.
+func JSONHandlerWithStatus(data []byte, fallback http.Handler, status int) (http.HandlerFunc, error) {
+	opts, err := statusOptions(status)
 	if err != nil {
 		return nil, err
 	}
-	paths := buildRedirectMap(jsonPaths)
+	return JSONHandlerWithOptions(data, fallback, opts)
+}
 
-	return func(w http.ResponseWriter, r *http.Request) {
-		path, ok := paths[r.URL.Path]
-		if ok {
-			http.Redirect(w, r, path, http.StatusFound)
-		} else {
-			fallback.ServeHTTP(w, r)
-		}
-	}, nil
+// JSONHandlerWithOptions behaves like JSONHandler but responds to matched paths as configured by opts.
+func JSONHandlerWithOptions(data []byte, fallback http.Handler, opts Options) (http.HandlerFunc, error) {
+	decode := decodeJSONEntries
+	if opts.StrictFields {
+		decode = decodeJSONEntriesStrict
+	}
+	entries, err := decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	return entriesHandler(entries, fallback, opts)
+}
+
+// YAMLHandlerReader behaves like YAMLHandler but decodes the YAML
+// straight from r instead of requiring it to be read into memory
+// first. Errors reading from r are returned as is.
+func YAMLHandlerReader(r io.Reader, fallback http.Handler) (http.HandlerFunc, error) {
+	entries, err := decodeYAMLEntries(r)
+	if err != nil {
+		return nil, err
+	}
+	return entriesHandler(entries, fallback, Options{})
+}
+
+// JSONHandlerReader behaves like JSONHandler but decodes the JSON straight from r.
+func JSONHandlerReader(r io.Reader, fallback http.Handler) (http.HandlerFunc, error) {
+	entries, err := decodeJSONEntries(r)
+	if err != nil {
+		return nil, err
+	}
+	return entriesHandler(entries, fallback, Options{})
 }
 
-// BoltHandler reads a BoltDB of url handler mappings an redirects base on those inputs.
+// TOMLHandler parses toml []byte of url handler mappings and redirects based on those inputs.
 // Else falls back to provided Handler.
-func BoltHandler(boltFile string, fallback http.Handler) (http.HandlerFunc, error) {
-	db, err := bolt.Open(boltFile, 0600, &bolt.Options{Timeout: 10 * time.Second})
+//
+// TOML is expected to be an array of tables named redirects:
+//
+//     [[redirects]]
+//     path = "/some-path"
+//     url = "https://www.some-url.com/demo"
+func TOMLHandler(data []byte, fallback http.Handler) (http.HandlerFunc, error) {
+	return TOMLHandlerWithStatus(data, fallback, http.StatusFound)
+}
+
+// TOMLHandlerWithStatus behaves like TOMLHandler but redirects using the provided status code.
+func TOMLHandlerWithStatus(data []byte, fallback http.Handler, status int) (http.HandlerFunc, error) {
+	opts, err := statusOptions(status)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-	defer db.Close()
+	return TOMLHandlerWithOptions(data, fallback, opts)
+}
 
-	// This bit of code is to be run if the Bolt file does not exist.
-	db.Update(func(tx *bolt.Tx) error {
-		b, err2 := tx.CreateBucket([]byte("URLRedirects"))
-		if err != nil {
-			return fmt.Errorf("create bucket: %s", err2)
-		}
-		err := b.Put([]byte("/urlshort-bolt"), []byte("https://github.com/bcpoole/urlshort"))
-		if err != nil {
-			return fmt.Errorf("put: %s", err2)
-		}
-		return nil
-	})
+// TOMLHandlerWithOptions behaves like TOMLHandler but responds to matched paths as configured by opts.
+func TOMLHandlerWithOptions(data []byte, fallback http.Handler, opts Options) (http.HandlerFunc, error) {
+	entries, err := parseTOMLEntriesStrict(data, opts.StrictFields)
+	if err != nil {
+		return nil, err
+	}
 
-	paths := make(map[string]string)
-	db.View(func(tx *bolt.Tx) error {
-		// Assume bucket exists and has keys
-		b := tx.Bucket([]byte("URLRedirects"))
+	return entriesHandler(entries, fallback, opts)
+}
 
-		c := b.Cursor()
+// CSVHandler parses csv []byte of url handler mappings and redirects based on those inputs.
+// Else falls back to provided Handler.
+//
+// The first row must be a header naming the path and url columns. Columns are
+// matched by name, so their order does not matter and extra columns are ignored:
+//
+//     path,url
+//     /some-path,https://www.some-url.com/demo
+func CSVHandler(data []byte, fallback http.Handler) (http.HandlerFunc, error) {
+	return CSVHandlerWithStatus(data, fallback, http.StatusFound)
+}
 
-		for k, v := c.First(); k != nil; k, v = c.Next() {
-			paths[string(k)] = string(v)
-		}
+// CSVHandlerWithStatus behaves like CSVHandler but redirects using the provided status code.
+func CSVHandlerWithStatus(data []byte, fallback http.Handler, status int) (http.HandlerFunc, error) {
+	opts, err := statusOptions(status)
+	if err != nil {
+		return nil, err
+	}
+	return CSVHandlerWithOptions(data, fallback, opts)
+}
 
-		return nil
-	})
-
-	return func(w http.ResponseWriter, r *http.Request) {
-		path, ok := paths[r.URL.Path]
-		if ok {
-			http.Redirect(w, r, path, http.StatusFound)
-		} else {
-			fallback.ServeHTTP(w, r)
+// CSVHandlerWithOptions behaves like CSVHandler but responds to matched paths as configured by opts.
+func CSVHandlerWithOptions(data []byte, fallback http.Handler, opts Options) (http.HandlerFunc, error) {
+	entries, err := parseCSVEntries(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return entriesHandler(entries, fallback, opts)
+}
+
+// entriesHandler builds the handler for parsed mapping entries.
+func entriesHandler(entries []entry, fallback http.Handler, opts Options) (http.HandlerFunc, error) {
+	store, err := opts.entryStore(entries)
+	if err != nil {
+		return nil, err
+	}
+	// The store already adds the UTM parameters, merged with
+	// those of each entry.
+	opts.UTM = nil
+	return StoreHandlerWithOptions(store, fallback, opts)
+}
+
+// statusOptions returns the Options for a handler that redirects
+// with status, or an error if status is not a redirect code.
+func statusOptions(status int) (Options, error) {
+	if err := checkRedirectStatus(status); err != nil {
+		return Options{}, err
+	}
+	return Options{Status: status}, nil
+}
+
+// checkTargets returns an error listing every entry with a
+// target that is not an absolute URL.
+func checkTargets(entries []entry) error {
+	var bad []string
+	for _, e := range entries {
+		for _, target := range e.urls() {
+			if err := checkAbsoluteURL(target); err != nil {
+				bad = append(bad, fmt.Sprintf("%s: %s", e.Path, err))
+			}
 		}
-	}, nil
+	}
+	if len(bad) == 0 {
+		return nil
+	}
+	sort.Strings(bad)
+	return fmt.Errorf("invalid redirect targets: %s", strings.Join(bad, "; "))
 }
 
-func buildRedirectMap(data []map[string]string) map[string]string {
-	redirects := make(map[string]string)
-	for _, m := range data {
-		redirects[m["path"]] = m["url"]
+// checkAbsoluteURL reports an error if target does not parse as
+// an absolute URL with both a scheme and a host.
+func checkAbsoluteURL(target string) error {
+	u, err := url.Parse(target)
+	if err != nil {
+		return err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("not an absolute url: %q", target)
+	}
+	return nil
+}
+
+// CheckTarget reports an error if target is not an absolute URL
+// with both a scheme and a host. WritableStores use it to reject
+// targets a redirect could not be sent to.
+func CheckTarget(target string) error {
+	return checkAbsoluteURL(target)
+}
+
+// checkRedirectStatus reports an error if status is not one of
+// the HTTP redirect codes a handler is allowed to respond with.
+func checkRedirectStatus(status int) error {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return nil
 	}
-	return redirects
+	return fmt.Errorf("invalid redirect status: %d", status)
 }
@@ -0,0 +1,234 @@
+package urlshort
+
+import (
+	"errors"
+	htmltemplate "html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+// notFound is the fallback handler used by most tests.
+var notFound = http.NotFoundHandler()
+
+// errStore is a Store whose lookups always fail.
+type errStore struct{}
+
+func (errStore) Lookup(string) (string, bool, error) {
+	return "", false, errors.New("boom")
+}
+
+// serve sends a request with the given method and target to h and
+// returns the recorded response.
+func serve(h http.Handler, method, target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+	return w
+}
+
+// expectRedirect fails the test unless a GET for target is redirected
+// to location with the given status.
+func expectRedirect(t *testing.T, h http.Handler, target string, status int, location string) {
+	t.Helper()
+	w := serve(h, http.MethodGet, target)
+	if w.Code != status || w.Header().Get("Location") != location {
+		t.Errorf("GET %s = %d %q, want %d %q", target, w.Code, w.Header().Get("Location"), status, location)
+	}
+}
+
+// expectStatus fails the test unless a GET for target is answered
+// with the given status.
+func expectStatus(t *testing.T, h http.Handler, target string, status int) {
+	t.Helper()
+	if w := serve(h, http.MethodGet, target); w.Code != status {
+		t.Errorf("GET %s = %d, want %d", target, w.Code, status)
+	}
+}
+
+func TestMapHandler(t *testing.T) {
+	h := MapHandler(map[string]string{"/a": "https://a.com"}, notFound)
+	expectRedirect(t, h, "/a", http.StatusFound, "https://a.com")
+	expectStatus(t, h, "/b", http.StatusNotFound)
+}
+
+func TestMapHandlerWithStatus(t *testing.T) {
+	for _, status := range []int{301, 302, 303, 307, 308} {
+		h, err := MapHandlerWithStatus(map[string]string{"/a": "https://a.com"}, notFound, status)
+		if err != nil {
+			t.Fatalf("status %d: %v", status, err)
+		}
+		expectRedirect(t, h, "/a", status, "https://a.com")
+	}
+	for _, status := range []int{200, 304, 404} {
+		if _, err := MapHandlerWithStatus(nil, notFound, status); err == nil {
+			t.Errorf("status %d: expected an error", status)
+		}
+	}
+}
+
+func TestHEAD(t *testing.T) {
+	h := MapHandler(map[string]string{"/a": "https://a.com"}, notFound)
+	w := serve(h, http.MethodHead, "/a")
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "https://a.com" {
+		t.Fatalf("HEAD /a = %d %q, want 302 https://a.com", w.Code, w.Header().Get("Location"))
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("HEAD /a wrote a body: %q", w.Body)
+	}
+	if serve(h, http.MethodGet, "/a").Body.Len() == 0 {
+		t.Error("GET /a wrote no body")
+	}
+}
+
+func TestRedirectBody(t *testing.T) {
+	m := map[string]string{"/a": "https://a.com/?x=<1>"}
+	w := serve(MapHandler(m, notFound), http.MethodGet, "/a")
+	if w.Code != http.StatusFound || !strings.Contains(w.Body.String(), "<a href=") {
+		t.Fatalf("default body = %q, want a link", w.Body)
+	}
+
+	page := htmltemplate.Must(htmltemplate.New("page").Parse(`<p>Taking you to <a href="{{.URL}}">{{.URL}}</a> from {{.Path}}</p>`))
+	h, err := MapHandlerWithOptions(m, notFound, Options{RedirectPage: page, Status: http.StatusMovedPermanently})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w = serve(h, http.MethodGet, "/a")
+	want := `<p>Taking you to <a href="https://a.com/?x=%3c1%3e">https://a.com/?x=&lt;1&gt;</a> from /a</p>`
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "https://a.com/?x=<1>" || w.Body.String() != want {
+		t.Fatalf("templated redirect = %d %q %q", w.Code, w.Header().Get("Location"), w.Body)
+	}
+	if w := serve(h, http.MethodHead, "/a"); w.Code != http.StatusMovedPermanently || w.Body.Len() != 0 {
+		t.Errorf("HEAD /a = %d %q, want 301 and no body", w.Code, w.Body)
+	}
+	if w := serve(h, http.MethodPost, "/a"); w.Body.Len() != 0 {
+		t.Errorf("POST /a wrote a body: %q", w.Body)
+	}
+
+	broken := htmltemplate.Must(htmltemplate.New("page").Parse(`{{.Nope}}`))
+	h, _ = MapHandlerWithOptions(m, notFound, Options{RedirectPage: broken})
+	if w := serve(h, http.MethodGet, "/a"); !strings.Contains(w.Body.String(), "<a href=") {
+		t.Errorf("failing template body = %q, want the default link", w.Body)
+	}
+
+	h, _ = MapHandlerWithOptions(m, notFound, Options{RedirectPage: page, NoRedirectBody: true})
+	w = serve(h, http.MethodGet, "/a")
+	if w.Code != http.StatusFound || w.Header().Get("Location") == "" || w.Body.Len() != 0 {
+		t.Errorf("NoRedirectBody = %d %q, want 302 and no body", w.Code, w.Body)
+	}
+}
+
+func TestYAMLHandler(t *testing.T) {
+	for _, doc := range []string{
+		"/a: https://a.com\n",
+		"- path: /a\n  url: https://a.com\n",
+	} {
+		h, err := YAMLHandler([]byte(doc), notFound)
+		if err != nil {
+			t.Fatalf("%q: %v", doc, err)
+		}
+		expectRedirect(t, h, "/a", http.StatusFound, "https://a.com")
+	}
+	if _, err := YAMLHandler([]byte("just a string"), notFound); err == nil {
+		t.Error("expected an error for a scalar document")
+	}
+	if _, err := YAMLHandler(nil, notFound); err != nil {
+		t.Errorf("empty document: %v", err)
+	}
+}
+
+func TestJSONHandler(t *testing.T) {
+	for _, doc := range []string{
+		`{"/a": "https://a.com"}`,
+		`[{"path": "/a", "url": "https://a.com"}]`,
+	} {
+		h, err := JSONHandler([]byte(doc), notFound)
+		if err != nil {
+			t.Fatalf("%s: %v", doc, err)
+		}
+		expectRedirect(t, h, "/a", http.StatusFound, "https://a.com")
+	}
+	if _, err := JSONHandler([]byte(`"x"`), notFound); err == nil {
+		t.Error("expected an error for a string document")
+	}
+}
+
+func TestHandlerReaders(t *testing.T) {
+	h, err := YAMLHandlerReader(strings.NewReader("/a: https://a.com\n"), notFound)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectRedirect(t, h, "/a", http.StatusFound, "https://a.com")
+
+	h, err = JSONHandlerReader(strings.NewReader(`[{"path": "/a", "url": "https://a.com"}]`), notFound)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectRedirect(t, h, "/a", http.StatusFound, "https://a.com")
+
+	readErr := errors.New("read failed")
+	if _, err := JSONHandlerReader(iotest.ErrReader(readErr), notFound); err != readErr {
+		t.Errorf("JSONHandlerReader error = %v, want %v", err, readErr)
+	}
+	if _, err := YAMLHandlerReader(iotest.ErrReader(readErr), notFound); err == nil {
+		t.Error("YAMLHandlerReader: expected the read error")
+	}
+}
+
+func TestTOMLHandler(t *testing.T) {
+	h, err := TOMLHandler([]byte("[[redirects]]\npath = \"/t\"\nurl = \"https://t.com\"\n"), notFound)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectRedirect(t, h, "/t", http.StatusFound, "https://t.com")
+	if _, err := TOMLHandler([]byte("[[redirects"), notFound); err == nil {
+		t.Error("expected an error for malformed TOML")
+	}
+}
+
+func TestCSVHandler(t *testing.T) {
+	for _, doc := range []string{
+		"path,url\n/a,https://a.com\n",
+		"note,url,path\nx,https://a.com,/a\n",
+	} {
+		h, err := CSVHandler([]byte(doc), notFound)
+		if err != nil {
+			t.Fatalf("%q: %v", doc, err)
+		}
+		expectRedirect(t, h, "/a", http.StatusFound, "https://a.com")
+	}
+	for _, doc := range []string{
+		"path,url\n/a\n",
+		"path,url\n\"/a,x\n",
+		"p,u\n/a,b\n",
+	} {
+		if _, err := CSVHandler([]byte(doc), notFound); err == nil {
+			t.Errorf("%q: expected an error", doc)
+		}
+	}
+}
+
+func TestEntryStatus(t *testing.T) {
+	doc := "- path: /api\n  url: https://api.com/submit\n  status: 307\n" +
+		"- path: /p\n  url: https://p.com\n  status: 308\n" +
+		"- path: /g\n  url: https://g.com\n"
+	h, err := YAMLHandlerWithOptions([]byte(doc), notFound, Options{TrailingSlash: true, CacheMaxAge: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := serve(h, http.MethodPost, "/api/")
+	if w.Code != http.StatusTemporaryRedirect || w.Header().Get("Location") != "https://api.com/submit" {
+		t.Errorf("POST /api/ = %d %q, want 307 https://api.com/submit", w.Code, w.Header().Get("Location"))
+	}
+	if w := serve(h, http.MethodPost, "/p"); w.Code != http.StatusPermanentRedirect || w.Header().Get("Cache-Control") == "" {
+		t.Errorf("POST /p = %d with Cache-Control %q, want a cacheable 308", w.Code, w.Header().Get("Cache-Control"))
+	}
+	if w := serve(h, http.MethodPost, "/g"); w.Code != http.StatusFound {
+		t.Errorf("POST /g = %d, want the default 302", w.Code)
+	}
+	if _, err := YAMLHandler([]byte("- path: /a\n  url: https://a.com\n  status: 200\n"), notFound); err == nil {
+		t.Error("expected an error for status 200")
+	}
+}
@@ -0,0 +1,36 @@
+package urlshort
+
+import (
+	"context"
+	"net/http"
+)
+
+// Pinger is implemented by stores that can check whether their
+// backing database is reachable.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// healthStatus is the body of a HealthHandler response.
+type healthStatus struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// HealthHandler will return an http.Handler suitable for liveness
+// and readiness probes. If store is a Pinger, each request pings
+// it and responds with http.StatusServiceUnavailable and the error
+// when the ping fails. Otherwise, as for in-memory stores, the
+// store is always healthy. The response body is a small JSON
+// object such as {"status": "ok"}.
+func HealthHandler(store Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p, ok := store.(Pinger); ok {
+			if err := p.Ping(r.Context()); err != nil {
+				writeJSON(w, http.StatusServiceUnavailable, healthStatus{Status: "unavailable", Error: err.Error()})
+				return
+			}
+		}
+		writeJSON(w, http.StatusOK, healthStatus{Status: "ok"})
+	})
+}
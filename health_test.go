@@ -0,0 +1,40 @@
+package urlshort
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// pingStore is a Pinger whose Ping returns err.
+type pingStore struct {
+	MapStore
+	err error
+}
+
+func (p pingStore) Ping(context.Context) error { return p.err }
+
+func TestHealthHandler(t *testing.T) {
+	w := serve(HealthHandler(MapStore{}), http.MethodGet, "/healthz")
+	if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != `{"status":"ok"}` {
+		t.Errorf("store without Ping = %d %q", w.Code, w.Body)
+	}
+
+	w = serve(HealthHandler(pingStore{err: errors.New("down")}), http.MethodGet, "/healthz")
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), "down") {
+		t.Errorf("failing Ping = %d %q", w.Code, w.Body)
+	}
+}
+
+func TestHealthHandlerBolt(t *testing.T) {
+	s, err := OpenBoltStore(t.TempDir() + "/h.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := HealthHandler(s)
+	expectStatus(t, h, "/healthz", http.StatusOK)
+	s.Close()
+	expectStatus(t, h, "/healthz", http.StatusServiceUnavailable)
+}
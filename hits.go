@@ -0,0 +1,88 @@
+package urlshort
+
+import (
+	"net/http"
+	"sort"
+	"time"
+)
+
+// HitStore is implemented by stores that count how many times
+// each path has been looked up, such as a BoltStore with hit
+// counting enabled.
+type HitStore interface {
+	EachHit(fn func(path string, hits uint64) error) error
+}
+
+// LinkStat holds the statistics of a path. Times a store does
+// not know are nil.
+type LinkStat struct {
+	Path       string     `json:"path"`
+	Hits       uint64     `json:"hits"`
+	LastAccess *time.Time `json:"last_access,omitempty"`
+	Created    *time.Time `json:"created,omitempty"`
+}
+
+// StatStore is implemented by stores that keep statistics of
+// their redirects, such as a BoltStore.
+type StatStore interface {
+	Stats(path string) (stat LinkStat, ok bool, err error)
+}
+
+// TopLinks returns the n paths of store with the most hits, most
+// hit first. Paths with the same number of hits are ordered by
+// path. Fewer than n are returned if store has fewer paths, and
+// n <= 0 returns them all.
+func TopLinks(store HitStore, n int) ([]LinkStat, error) {
+	var stats []LinkStat
+	err := store.EachHit(func(path string, hits uint64) error {
+		stats = append(stats, LinkStat{Path: path, Hits: hits})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Hits != stats[j].Hits {
+			return stats[i].Hits > stats[j].Hits
+		}
+		return stats[i].Path < stats[j].Path
+	})
+	if n > 0 && len(stats) > n {
+		stats = stats[:n]
+	}
+	return stats, nil
+}
+
+// StatsHandler will return an http.Handler answering
+// GET /admin/stats?path=/x with the LinkStat of /x as JSON, or
+// with 404 if store has no redirect for /x.
+//
+// Like AdminHandler, the handler performs no authentication.
+func StatsHandler(store StatStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/admin/stats" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		path := r.URL.Query().Get("path")
+		if path == "" {
+			http.Error(w, "path is required", http.StatusBadRequest)
+			return
+		}
+		stat, ok, err := store.Stats(path)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			http.Error(w, path+" not found", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, stat)
+	})
+}
@@ -0,0 +1,96 @@
+package urlshort
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// hitMap is a HitStore holding hit counts by path.
+type hitMap map[string]uint64
+
+func (m hitMap) EachHit(fn func(string, uint64) error) error {
+	for p, h := range m {
+		if err := fn(p, h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestTopLinks(t *testing.T) {
+	got, err := TopLinks(hitMap{"/a": 3, "/b": 5, "/c": 3, "/d": 1}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []LinkStat{{Path: "/b", Hits: 5}, {Path: "/a", Hits: 3}, {Path: "/c", Hits: 3}}
+	if len(got) != len(want) {
+		t.Fatalf("TopLinks = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i].Path != want[i].Path || got[i].Hits != want[i].Hits {
+			t.Errorf("TopLinks[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	if got, _ := TopLinks(hitMap{"/a": 1}, 5); len(got) != 1 {
+		t.Errorf("TopLinks of one path = %v", got)
+	}
+
+	s := openTestBolt(t, BoltOptions{CountHits: true})
+	s.Put("/x", "https://x.com")
+	s.Put("/y", "https://y.com")
+	s.Lookup("/x")
+	s.Lookup("/y")
+	s.Lookup("/y")
+	got, err = TopLinks(s, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Path != "/y" || got[0].Hits != 2 || got[1].Hits != 1 {
+		t.Errorf("TopLinks of BoltStore = %+v", got)
+	}
+}
+
+func TestStatsHandler(t *testing.T) {
+	s := openTestBolt(t, BoltOptions{CountHits: true})
+	before := time.Now()
+	s.Put("/x", "https://x.com")
+	sh := StatsHandler(s)
+	stats := func() LinkStat {
+		t.Helper()
+		w := serve(sh, http.MethodGet, "/admin/stats?path=/x")
+		var stat LinkStat
+		if err := json.Unmarshal(w.Body.Bytes(), &stat); err != nil {
+			t.Fatalf("decode %q: %v", w.Body, err)
+		}
+		return stat
+	}
+
+	stat := stats()
+	if stat.Hits != 0 || stat.Created == nil || stat.LastAccess != nil {
+		t.Fatalf("stats of a new link = %+v", stat)
+	}
+	created := *stat.Created
+
+	h := StoreHandler(s, notFound)
+	serve(h, http.MethodGet, "/x")
+	serve(h, http.MethodGet, "/x")
+	stat = stats()
+	if stat.Hits != 2 || stat.LastAccess == nil || stat.LastAccess.Before(before) || stat.LastAccess.After(time.Now()) {
+		t.Errorf("stats after two hits = %+v", stat)
+	}
+
+	s.Put("/x", "https://y.com")
+	if c := stats().Created; c == nil || !c.Equal(created) {
+		t.Errorf("Created changed to %v on update, want %v", c, created)
+	}
+	s.Delete("/x")
+	s.Put("/x", "https://z.com")
+	if c := stats().Created; c == nil || c.Equal(created) {
+		t.Errorf("Created kept %v after delete and re-create", c)
+	}
+
+	expectStatus(t, sh, "/admin/stats?path=/nope", http.StatusNotFound)
+	expectStatus(t, sh, "/admin/stats", http.StatusBadRequest)
+}
@@ -0,0 +1,166 @@
+package urlshort
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+)
+
+// DefaultHTTPConfigTimeout is how long HTTPConfigHandler waits
+// for the config server to answer a fetch.
+const DefaultHTTPConfigTimeout = 10 * time.Second
+
+// DefaultHTTPConfigMaxSize is the largest mapping, in bytes,
+// HTTPConfigHandler accepts from the config server.
+const DefaultHTTPConfigMaxSize = 10 << 20
+
+// HTTPConfigOptions configures HTTPConfigHandlerWithOptions.
+type HTTPConfigOptions struct {
+	// Client sends the requests. Nil means http.DefaultClient.
+	Client *http.Client
+
+	// Timeout bounds each fetch, including reading the body. Zero
+	// means DefaultHTTPConfigTimeout.
+	Timeout time.Duration
+
+	// MaxSize is the largest mapping, in bytes, that is accepted.
+	// A larger response fails the fetch. Zero means
+	// DefaultHTTPConfigMaxSize.
+	MaxSize int64
+
+	// Options configures how the fetched mapping is parsed and
+	// served, on every refresh.
+	Options Options
+}
+
+// HTTPConfigHandler behaves like JSONHandler but fetches the JSON from configURL and
+// fetches it again every refresh interval. Refreshes send the ETag and Last-Modified
+// of the previous response, so an unchanged mapping is not downloaded again. A refresh
+// that fails is logged and the last good mapping keeps being served. The returned
+// function stops refreshing, cancelling a fetch in progress.
+func HTTPConfigHandler(configURL string, refresh time.Duration, fallback http.Handler) (http.HandlerFunc, func() error, error) {
+	return HTTPConfigHandlerWithOptions(configURL, refresh, fallback, HTTPConfigOptions{})
+}
+
+// HTTPConfigHandlerWithOptions behaves like HTTPConfigHandler but fetches as configured
+// by opts.
+func HTTPConfigHandlerWithOptions(configURL string, refresh time.Duration, fallback http.Handler, opts HTTPConfigOptions) (http.HandlerFunc, func() error, error) {
+	if refresh <= 0 {
+		return nil, nil, fmt.Errorf("invalid refresh interval: %s", refresh)
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultHTTPConfigTimeout
+	}
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = DefaultHTTPConfigMaxSize
+	}
+	src := &httpConfig{url: configURL, client: opts.Client, timeout: opts.Timeout, maxSize: opts.MaxSize, opts: opts.Options}
+	ctx, cancel := context.WithCancel(context.Background())
+	entries, _, err := src.fetch(ctx)
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+	store := &swapStore{store: entries}
+	h, err := opts.Options.swapHandler(store, fallback)
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(refresh)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				entries, changed, err := src.fetch(ctx)
+				if err != nil {
+					if ctx.Err() == nil {
+						log.Printf("urlshort: refresh %s: %v", configURL, err)
+					}
+					continue
+				}
+				if changed {
+					store.swap(entries)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	stop := func() error {
+		cancel()
+		<-done
+		return nil
+	}
+	return h, stop, nil
+}
+
+// httpConfig fetches a JSON mapping over HTTP, remembering the
+// validators of the last response.
+type httpConfig struct {
+	url     string
+	client  *http.Client
+	timeout time.Duration
+	maxSize int64
+	opts    Options
+
+	etag         string
+	lastModified string
+}
+
+// fetch downloads and parses the mapping. It reports changed as
+// false, with a nil store, if the server says the mapping has not
+// changed since the last fetch. The fetch gives up once ctx is
+// done or the timeout of c passes.
+func (c *httpConfig) fetch(ctx context.Context) (store *entryStore, changed bool, err error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	if c.etag != "" {
+		req.Header.Set("If-None-Match", c.etag)
+	}
+	if c.lastModified != "" {
+		req.Header.Set("If-Modified-Since", c.lastModified)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, c.maxSize+1))
+	if err != nil {
+		return nil, false, err
+	}
+	if int64(len(data)) > c.maxSize {
+		return nil, false, fmt.Errorf("mapping is larger than %d bytes", c.maxSize)
+	}
+	store, err = c.opts.parseEntryStore(data, FormatJSON)
+	if err != nil {
+		return nil, false, err
+	}
+	c.etag = resp.Header.Get("ETag")
+	c.lastModified = resp.Header.Get("Last-Modified")
+	return store, true, nil
+}
@@ -0,0 +1,125 @@
+package urlshort
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// configServer serves a mapping document with an ETag, answering
+// 304 to requests that already have it.
+type configServer struct {
+	mu      sync.Mutex
+	body    string
+	etag    string
+	fetches int
+}
+
+func (c *configServer) set(body, etag string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.body, c.etag = body, etag
+}
+
+func (c *configServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if r.Header.Get("If-None-Match") == c.etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	c.fetches++
+	w.Header().Set("ETag", c.etag)
+	w.Write([]byte(c.body))
+}
+
+func TestHTTPConfigHandler(t *testing.T) {
+	cs := &configServer{body: `{"/a": "https://a.com"}`, etag: `"v1"`}
+	srv := httptest.NewServer(cs)
+	defer srv.Close()
+
+	h, stop, err := HTTPConfigHandler(srv.URL, 20*time.Millisecond, notFound)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	expectRedirect(t, h, "/a", http.StatusFound, "https://a.com")
+
+	time.Sleep(80 * time.Millisecond)
+	cs.mu.Lock()
+	fetches := cs.fetches
+	cs.mu.Unlock()
+	if fetches != 1 {
+		t.Errorf("fetched the unchanged document %d times, want 1", fetches)
+	}
+
+	cs.set(`[{"path": "/b", "url": "https://b.com", "status": 301}]`, `"v2"`)
+	if !eventually(func() bool { return serve(h, http.MethodGet, "/b").Code == http.StatusMovedPermanently }) {
+		t.Fatal("new document was not picked up")
+	}
+
+	// A broken document keeps the last good mappings.
+	cs.set(`garbage`, `"v3"`)
+	time.Sleep(80 * time.Millisecond)
+	expectStatus(t, h, "/b", http.StatusMovedPermanently)
+}
+
+func TestHTTPConfigHandlerTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+	_, _, err := HTTPConfigHandlerWithOptions(srv.URL, time.Second, notFound, HTTPConfigOptions{Timeout: 50 * time.Millisecond})
+	if err == nil {
+		t.Error("expected the initial fetch to time out")
+	}
+}
+
+func TestHTTPConfigHandlerStop(t *testing.T) {
+	block := make(chan struct{})
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Write([]byte(`{"/a": "https://a.com"}`))
+			return
+		}
+		select {
+		case <-block:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(block)
+
+	_, stop, err := HTTPConfigHandlerWithOptions(srv.URL, 10*time.Millisecond, notFound, HTTPConfigOptions{Timeout: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	start := time.Now()
+	stop()
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("stop waited %v for a hanging fetch", d)
+	}
+}
+
+func TestHTTPConfigHandlerOptions(t *testing.T) {
+	cs := &configServer{body: `{"/a": "https://a.com"}`, etag: `"v1"`}
+	srv := httptest.NewServer(cs)
+	defer srv.Close()
+
+	h, stop, err := HTTPConfigHandlerWithOptions(srv.URL, time.Hour, notFound, HTTPConfigOptions{Options: Options{Status: http.StatusMovedPermanently}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	expectRedirect(t, h, "/a", http.StatusMovedPermanently, "https://a.com")
+
+	cs.set(`{"/a": "https://a.com", "/b": "https://b.com"}`, `"v2"`)
+	if _, _, err := HTTPConfigHandlerWithOptions(srv.URL, time.Hour, notFound, HTTPConfigOptions{MaxSize: 30}); err == nil {
+		t.Error("expected an error for a mapping larger than MaxSize")
+	}
+}
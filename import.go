@@ -0,0 +1,94 @@
+package urlshort
+
+import "fmt"
+
+// ImportOptions configures Import.
+type ImportOptions struct {
+	// Overwrite replaces the existing mapping of a path in the
+	// store. By default such paths are skipped.
+	Overwrite bool
+}
+
+// Import writes the mappings of a document in the given format to
+// store, in path order. Paths the store already has are skipped
+// or overwritten as configured by opts. Skipping uses the Insert
+// of stores that are InsertStores, so a path created concurrently
+// is not overwritten. The document is checked before anything is
+// written: an error is returned, and nothing imported, if it
+// cannot be parsed, defines a path more than once or has a target
+// that is not an absolute URL.
+//
+// added counts the mappings written, including overwritten ones,
+// and skipped those left alone. If writing fails part way, the
+// counts cover the mappings handled before the failure.
+func Import(store WritableStore, data []byte, format Format, opts ImportOptions) (added, skipped int, err error) {
+	entries, err := decodeEntries(data, format)
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := checkTargets(entries); err != nil {
+		return 0, 0, err
+	}
+	paths, err := buildRedirectMapStrict(entries)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, e := range mapEntries(paths) {
+		if opts.Overwrite {
+			if err := store.Put(e.Path, e.URL); err != nil {
+				return added, skipped, err
+			}
+			added++
+			continue
+		}
+		inserted, err := insert(store, e.Path, e.URL)
+		if err != nil {
+			return added, skipped, err
+		}
+		if inserted {
+			added++
+		} else {
+			skipped++
+		}
+	}
+	return added, skipped, nil
+}
+
+// Migrate copies every mapping of src to dst, replacing the
+// mappings dst already has for the same paths, and returns how
+// many were copied. Running it again copies the same mappings, so
+// it is safe to repeat after a failure. src must be a
+// ListableStore and dst a WritableStore, such as a BoltStore or
+// the stores of the redis and memcached packages; otherwise an
+// error is returned before anything is copied. Read-only stores,
+// such as SQLStore, can only be sources.
+//
+// The mappings of src are read before any is written, so src and
+// dst may share a database, such as two tenants of a BoltStore.
+func Migrate(src, dst Store) (count int, err error) {
+	ls, ok := src.(ListableStore)
+	if !ok {
+		return 0, errNotListable
+	}
+	ws, ok := dst.(WritableStore)
+	if !ok {
+		return 0, errNotWritable
+	}
+
+	var entries []entry
+	err = ls.Each(func(path, url string) error {
+		entries = append(entries, entry{Path: path, URL: url})
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	for _, e := range entries {
+		if err := ws.Put(e.Path, e.URL); err != nil {
+			return count, fmt.Errorf("put %s: %s", e.Path, err)
+		}
+		count++
+	}
+	return count, nil
+}
@@ -0,0 +1,88 @@
+package urlshort
+
+import "testing"
+
+func TestImport(t *testing.T) {
+	m := NewMutableMapStore(map[string]string{"/a": "https://old.com"})
+	doc := []byte(`[{"path": "/a", "url": "https://new.com"}, {"path": "/b", "url": "https://b.com"}]`)
+
+	added, skipped, err := Import(m, doc, FormatJSON, ImportOptions{})
+	if added != 1 || skipped != 1 || err != nil {
+		t.Fatalf("Import = %d, %d, %v, want 1 added and 1 skipped", added, skipped, err)
+	}
+	if u, _, _ := m.Lookup("/a"); u != "https://old.com" {
+		t.Errorf("Import replaced /a with %q without Overwrite", u)
+	}
+
+	added, skipped, err = Import(m, doc, FormatJSON, ImportOptions{Overwrite: true})
+	if added != 2 || skipped != 0 || err != nil {
+		t.Fatalf("Import with Overwrite = %d, %d, %v, want 2 added", added, skipped, err)
+	}
+	if u, _, _ := m.Lookup("/a"); u != "https://new.com" {
+		t.Errorf("Lookup(/a) = %q after Overwrite, want https://new.com", u)
+	}
+}
+
+// insertCounter is a MutableMapStore that counts its Insert calls.
+type insertCounter struct {
+	*MutableMapStore
+	inserts int
+}
+
+func (s *insertCounter) Insert(path, url string) (bool, error) {
+	s.inserts++
+	if _, ok, _ := s.Lookup(path); ok {
+		return false, nil
+	}
+	return true, s.Put(path, url)
+}
+
+func TestImportInsert(t *testing.T) {
+	s := &insertCounter{MutableMapStore: NewMutableMapStore(map[string]string{"/a": "https://old.com"})}
+	added, skipped, err := Import(s, []byte("/a: https://new.com\n/b: https://b.com\n"), FormatYAML, ImportOptions{})
+	if added != 1 || skipped != 1 || err != nil {
+		t.Fatalf("Import = %d, %d, %v, want 1 added and 1 skipped", added, skipped, err)
+	}
+	if s.inserts != 2 {
+		t.Errorf("Import made %d Insert calls, want 2", s.inserts)
+	}
+	if u, _, _ := s.Lookup("/a"); u != "https://old.com" {
+		t.Errorf("Import replaced /a with %q", u)
+	}
+}
+
+func TestImportInvalid(t *testing.T) {
+	m := NewMutableMapStore(nil)
+	added, _, err := Import(m, []byte("/c: https://c.com\n/d: nope\n"), FormatYAML, ImportOptions{})
+	if err == nil || added != 0 {
+		t.Fatalf("Import = %d, %v, want an error and nothing added", added, err)
+	}
+	if _, ok, _ := m.Lookup("/c"); ok {
+		t.Error("a failed import wrote /c")
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	src := MapStore{"/a": "https://a.com", "/b": "https://b.com"}
+	dst := NewMutableMapStore(map[string]string{"/a": "https://old.com", "/c": "https://c.com"})
+	for i := 0; i < 2; i++ {
+		if n, err := Migrate(src, dst); err != nil || n != 2 {
+			t.Fatalf("Migrate run %d = %d, %v, want 2", i, n, err)
+		}
+	}
+	got := map[string]string{}
+	dst.Each(func(path, url string) error {
+		got[path] = url
+		return nil
+	})
+	if len(got) != 3 || got["/a"] != "https://a.com" || got["/b"] != "https://b.com" || got["/c"] != "https://c.com" {
+		t.Errorf("after Migrate, dst = %v", got)
+	}
+
+	if _, err := Migrate(src, src); err == nil {
+		t.Error("expected an error migrating into a read-only store")
+	}
+	if _, err := Migrate(errStore{}, dst); err == nil {
+		t.Error("expected an error migrating from an unlistable store")
+	}
+}
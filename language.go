@@ -0,0 +1,47 @@
+package urlshort
+
+import (
+	"golang.org/x/text/language"
+)
+
+// languageTargets picks a target by matching an Accept-Language
+// header against the languages of an entry.
+type languageTargets struct {
+	matcher language.Matcher
+	urls    []string
+}
+
+// newLanguageTargets parses the language tags of targets.
+func newLanguageTargets(targets map[string]string) (*languageTargets, error) {
+	// The first tag of a matcher is what it falls back to when
+	// nothing matches. language.Und never matches a real language,
+	// so a miss can be told apart from a match.
+	tags := []language.Tag{language.Und}
+	urls := []string{""}
+	for _, name := range sortedKeys(targets) {
+		tag, err := language.Parse(name)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+		urls = append(urls, targets[name])
+	}
+	return &languageTargets{matcher: language.NewMatcher(tags), urls: urls}, nil
+}
+
+// match returns the target for the best language in the
+// Accept-Language header value, if any.
+func (t *languageTargets) match(acceptLanguage string) (string, bool) {
+	if acceptLanguage == "" {
+		return "", false
+	}
+	preferred, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(preferred) == 0 {
+		return "", false
+	}
+	_, i, confidence := t.matcher.Match(preferred...)
+	if i == 0 || confidence == language.No {
+		return "", false
+	}
+	return t.urls[i], true
+}
@@ -0,0 +1,32 @@
+package urlshort
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLanguageTargets(t *testing.T) {
+	doc := "- path: /docs\n  url: https://en.com\n  languages:\n    de: https://de.com\n    fr: https://fr.com\n    pt-BR: https://br.com\n"
+	h, err := YAMLHandler([]byte(doc), notFound)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for accept, want := range map[string]string{
+		"de":             "https://de.com",
+		"fr-CA,en;q=0.5": "https://fr.com",
+		"pt-BR":          "https://br.com",
+		"ja":             "https://en.com",
+		"":               "https://en.com",
+		"en-US,de;q=0.3": "https://de.com",
+		"!!!":            "https://en.com",
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/docs", nil)
+		r.Header.Set("Accept-Language", accept)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if got := w.Header().Get("Location"); got != want {
+			t.Errorf("Accept-Language %q: Location = %q, want %q", accept, got, want)
+		}
+	}
+}
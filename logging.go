@@ -0,0 +1,57 @@
+package urlshort
+
+import (
+	"log"
+	"net/http"
+	"time"
+)
+
+// LoggingHandler will return an http.HandlerFunc that serves
+// next and then writes one line to logger describing the
+// request: its method and path, the redirect target or "miss"
+// if next did not redirect, the response status and how long
+// next took to respond. A nil logger logs to the standard
+// logger.
+func LoggingHandler(next http.Handler, logger *log.Logger) http.HandlerFunc {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+		latency := time.Since(start)
+
+		status := sw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		target := w.Header().Get("Location")
+		if target == "" || status < 300 || status >= 400 {
+			logger.Printf("urlshort: %s %s miss status=%d latency=%s", r.Method, r.URL.Path, status, latency)
+			return
+		}
+		logger.Printf("urlshort: %s %s -> %s status=%d latency=%s", r.Method, r.URL.Path, target, status, latency)
+	}
+}
+
+// statusWriter is an http.ResponseWriter that records the status
+// code of the response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
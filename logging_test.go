@@ -0,0 +1,23 @@
+package urlshort
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestLoggingHandler(t *testing.T) {
+	var buf bytes.Buffer
+	h := LoggingHandler(MapHandler(map[string]string{"/a": "https://a.com"}, notFound), log.New(&buf, "", 0))
+	serve(h, http.MethodGet, "/a")
+	serve(h, http.MethodGet, "/b")
+
+	out := buf.String()
+	for _, want := range []string{"GET /a -> https://a.com status=302", "GET /b miss status=404"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("log %q does not contain %q", out, want)
+		}
+	}
+}
@@ -22,13 +22,16 @@ func main() {
 	// Build the YAMLHandler using the mapHandler as the fallback
 	var yamlFile = flag.String("yamlfile", "urlmappings.yaml", "Provide absolute path for yaml file with redirect urls.")
 	var jsonFile = flag.String("jsonfile", "urlmappings.json", "Provide absolute path for json file with redirect urls.")
+	var tomlFile = flag.String("tomlfile", "", "Provide absolute path for toml file with redirect urls. TOML is not served if unset.")
 	var boltFile = flag.String("boltfile", "bolt.db", "Provide absolute path for bolt db file with redirect urls.")
 	flag.Parse()
 
-	boltHandler, err := urlshort.BoltHandler(*boltFile, mapHandler)
+	boltStore, err := urlshort.OpenBoltStoreWithOptions(*boltFile, urlshort.BoltOptions{Seed: true, Timeout: urlshort.DefaultBoltTimeout})
 	if err != nil {
 		panic(err)
 	}
+	defer boltStore.Close()
+	boltHandler := urlshort.StoreHandler(boltStore, mapHandler)
 
 	yaml, err := ioutil.ReadFile(*yamlFile)
 	if err != nil {
@@ -45,8 +48,20 @@ func main() {
 		panic(err)
 	}
 
+	handler := jsonHandler
+	if *tomlFile != "" {
+		tomlData, err := ioutil.ReadFile(*tomlFile)
+		if err != nil {
+			panic(err)
+		}
+		handler, err = urlshort.TOMLHandler(tomlData, jsonHandler)
+		if err != nil {
+			panic(err)
+		}
+	}
+
 	fmt.Println("Starting the server on :8080")
-	http.ListenAndServe(":8080", jsonHandler)
+	http.ListenAndServe(":8080", handler)
 }
 
 func defaultMux() *http.ServeMux {
@@ -0,0 +1,115 @@
+// Package memcached serves redirects stored as items in Memcached.
+package memcached
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/bcpoole/urlshort"
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// Store is a urlshort.Store backed by items in Memcached. Every
+// lookup queries Memcached, and items may be given an expiry, so
+// it suits short-lived links shared between instances. Memcached
+// may also evict items under memory pressure, so it should not be
+// the only copy of links that must keep working.
+type Store struct {
+	client    *memcache.Client
+	keyPrefix string
+}
+
+// NewStore returns a Store that resolves a path by reading the
+// item keyPrefix+path.
+func NewStore(client *memcache.Client, keyPrefix string) *Store {
+	return &Store{client: client, keyPrefix: keyPrefix}
+}
+
+// Lookup returns the url stored in the item for path. Paths that
+// cannot be Memcached keys, such as paths containing spaces or
+// longer than 250 bytes, are never found.
+func (s *Store) Lookup(path string) (string, bool, error) {
+	item, err := s.client.Get(s.keyPrefix + path)
+	if err == memcache.ErrCacheMiss || err == memcache.ErrMalformedKey {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return string(item.Value), true, nil
+}
+
+// Put stores a redirect from path to url that does not expire.
+// url must be an absolute URL.
+func (s *Store) Put(path, url string) error {
+	return s.PutWithExpiry(path, url, 0)
+}
+
+// PutWithExpiry stores a redirect from path to url that Memcached
+// removes once ttl has passed. A zero ttl never expires. url must
+// be an absolute URL.
+func (s *Store) PutWithExpiry(path, url string, ttl time.Duration) error {
+	if err := urlshort.CheckTarget(url); err != nil {
+		return err
+	}
+	return s.client.Set(&memcache.Item{
+		Key:        s.keyPrefix + path,
+		Value:      []byte(url),
+		Expiration: expiration(ttl),
+	})
+}
+
+// expiration converts ttl to a Memcached expiration, which is a
+// number of seconds up to 30 days and a Unix time beyond that.
+func expiration(ttl time.Duration) int32 {
+	if ttl <= 0 {
+		return 0
+	}
+	if ttl > 30*24*time.Hour {
+		return int32(time.Now().Add(ttl).Unix())
+	}
+	if ttl < time.Second {
+		return 1
+	}
+	return int32(ttl / time.Second)
+}
+
+// Insert stores a redirect from path to url that does not expire,
+// unless Memcached already has an item for path, and reports
+// whether it did. url must be an absolute URL.
+func (s *Store) Insert(path, url string) (bool, error) {
+	if err := urlshort.CheckTarget(url); err != nil {
+		return false, err
+	}
+	err := s.client.Add(&memcache.Item{Key: s.keyPrefix + path, Value: []byte(url)})
+	if err == memcache.ErrNotStored {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Delete removes the redirect for path. Deleting a path that has
+// no mapping is not an error.
+func (s *Store) Delete(path string) error {
+	err := s.client.Delete(s.keyPrefix + path)
+	if err == memcache.ErrCacheMiss {
+		return nil
+	}
+	return err
+}
+
+// Ping checks that the Memcached servers can be reached.
+func (s *Store) Ping(ctx context.Context) error {
+	return s.client.Ping()
+}
+
+// Handler looks up each request path in Memcached under keyPrefix and redirects to the stored
+// url. Else falls back to provided Handler, including when Memcached cannot be reached.
+func Handler(client *memcache.Client, keyPrefix string, fallback http.Handler) (http.HandlerFunc, error) {
+	if client == nil {
+		return nil, errors.New("memcache client is nil")
+	}
+	return urlshort.StoreHandler(NewStore(client, keyPrefix), fallback), nil
+}
@@ -0,0 +1,159 @@
+package memcached
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// expectStatus fails the test unless a GET for target is answered
+// by h with the given status.
+func expectStatus(t *testing.T, h http.Handler, target string, status int) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+	if w.Code != status {
+		t.Errorf("GET %s = %d, want %d", target, w.Code, status)
+	}
+}
+
+// fakeMemcached starts a server speaking enough of the memcached
+// text protocol for the Store and returns its address.
+func fakeMemcached(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	var mu sync.Mutex
+	data := map[string]string{}
+	serve := func(c net.Conn) {
+		defer c.Close()
+		rd := bufio.NewReader(c)
+		for {
+			line, err := rd.ReadString('\n')
+			if err != nil {
+				return
+			}
+			f := strings.Fields(line)
+			if len(f) == 0 {
+				continue
+			}
+			switch f[0] {
+			case "get", "gets":
+				mu.Lock()
+				for _, k := range f[1:] {
+					if v, ok := data[k]; ok {
+						fmt.Fprintf(c, "VALUE %s 0 %d 1\r\n%s\r\n", k, len(v), v)
+					}
+				}
+				mu.Unlock()
+				fmt.Fprint(c, "END\r\n")
+			case "set", "add":
+				n, _ := strconv.Atoi(f[4])
+				value := make([]byte, n+2)
+				if _, err := io.ReadFull(rd, value); err != nil {
+					return
+				}
+				mu.Lock()
+				if _, ok := data[f[1]]; ok && f[0] == "add" {
+					fmt.Fprint(c, "NOT_STORED\r\n")
+				} else {
+					data[f[1]] = string(value[:n])
+					fmt.Fprint(c, "STORED\r\n")
+				}
+				mu.Unlock()
+			case "delete":
+				mu.Lock()
+				if _, ok := data[f[1]]; ok {
+					delete(data, f[1])
+					fmt.Fprint(c, "DELETED\r\n")
+				} else {
+					fmt.Fprint(c, "NOT_FOUND\r\n")
+				}
+				mu.Unlock()
+			case "version":
+				fmt.Fprint(c, "VERSION 1.6.0\r\n")
+			default:
+				fmt.Fprint(c, "ERROR\r\n")
+			}
+		}
+	}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serve(c)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestStore(t *testing.T) {
+	client := memcache.New(fakeMemcached(t))
+	s := NewStore(client, "l:")
+	if err := s.PutWithExpiry("/a", "https://a.com", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put("/rel", "relative"); err == nil {
+		t.Error("expected an error for a relative URL")
+	}
+
+	h, err := Handler(client, "l:", http.NotFoundHandler())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, h, "/a", http.StatusFound)
+	if u, ok, _ := s.Lookup("/a"); !ok || u != "https://a.com" {
+		t.Errorf("Lookup(/a) = %q, %v", u, ok)
+	}
+	expectStatus(t, h, "/b", http.StatusNotFound)
+	expectStatus(t, h, "/a%20b", http.StatusNotFound)
+
+	if err := s.Delete("/a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete("/a"); err != nil {
+		t.Errorf("deleting a missing key: %v", err)
+	}
+	expectStatus(t, h, "/a", http.StatusNotFound)
+}
+
+func TestStoreInsert(t *testing.T) {
+	s := NewStore(memcache.New(fakeMemcached(t)), "")
+	if ok, err := s.Insert("/a", "https://a.com"); !ok || err != nil {
+		t.Fatalf("first Insert = %v, %v, want true", ok, err)
+	}
+	if ok, err := s.Insert("/a", "https://b.com"); ok || err != nil {
+		t.Fatalf("second Insert = %v, %v, want false", ok, err)
+	}
+	if u, _, _ := s.Lookup("/a"); u != "https://a.com" {
+		t.Errorf("Insert replaced the target with %q", u)
+	}
+}
+
+func TestHandlerErrors(t *testing.T) {
+	h, err := Handler(memcache.New("127.0.0.1:1"), "", http.NotFoundHandler())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, h, "/a", http.StatusNotFound)
+
+	if _, err := Handler(nil, "", http.NotFoundHandler()); err == nil {
+		t.Error("expected an error for a nil client")
+	}
+}
@@ -0,0 +1,98 @@
+// Package metrics records Prometheus metrics for urlshort handlers.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Options configures the metrics recorded by HandlerWithOptions.
+type Options struct {
+	// PathLabel adds the request path as a path label of
+	// urlshort_redirects_total. Only paths that redirected are
+	// labelled, but every distinct path still creates a series,
+	// so this is off by default.
+	PathLabel bool
+}
+
+// Handler will return an http.HandlerFunc that serves next
+// and records Prometheus metrics for every request with
+// registerer:
+//
+//	urlshort_redirects_total{status}     redirects served
+//	urlshort_miss_total                  requests that did not redirect
+//	urlshort_request_duration_seconds    time taken by next
+//
+// An error is returned if the metrics cannot be registered, for
+// instance because they already are.
+func Handler(next http.Handler, registerer prometheus.Registerer) (http.HandlerFunc, error) {
+	return HandlerWithOptions(next, registerer, Options{})
+}
+
+// HandlerWithOptions behaves like Handler but records metrics as
+// configured by opts.
+func HandlerWithOptions(next http.Handler, registerer prometheus.Registerer, opts Options) (http.HandlerFunc, error) {
+	labels := []string{"status"}
+	if opts.PathLabel {
+		labels = append(labels, "path")
+	}
+	redirects := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "urlshort_redirects_total",
+		Help: "Number of requests redirected, by status code.",
+	}, labels)
+	misses := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "urlshort_miss_total",
+		Help: "Number of requests that did not match a redirect.",
+	})
+	latency := prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "urlshort_request_duration_seconds",
+		Help:    "Time taken to respond to a request.",
+		Buckets: prometheus.DefBuckets,
+	})
+	for _, c := range []prometheus.Collector{redirects, misses, latency} {
+		if err := registerer.Register(c); err != nil {
+			return nil, err
+		}
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+		latency.Observe(time.Since(start).Seconds())
+
+		if sw.status < 300 || sw.status >= 400 || w.Header().Get("Location") == "" {
+			misses.Inc()
+			return
+		}
+		values := []string{strconv.Itoa(sw.status)}
+		if opts.PathLabel {
+			values = append(values, r.URL.Path)
+		}
+		redirects.WithLabelValues(values...).Inc()
+	}, nil
+}
+
+// statusWriter is an http.ResponseWriter that records the status
+// code of the response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bcpoole/urlshort"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestHandler(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	next := urlshort.MapHandler(map[string]string{"/a": "https://a.com"}, http.NotFoundHandler())
+	h, err := HandlerWithOptions(next, reg, Options{PathLabel: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"/a", "/a", "/b"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var misses, redirects float64
+	for _, mf := range families {
+		switch mf.GetName() {
+		case "urlshort_miss_total":
+			misses = mf.Metric[0].Counter.GetValue()
+		case "urlshort_redirects_total":
+			redirects = mf.Metric[0].Counter.GetValue()
+			if n := len(mf.Metric[0].Label); n != 2 {
+				t.Errorf("redirect counter has %d labels, want 2", n)
+			}
+		}
+	}
+	if misses != 1 || redirects != 2 {
+		t.Errorf("counted %v misses and %v redirects, want 1 and 2", misses, redirects)
+	}
+
+	if _, err := Handler(next, reg); err == nil {
+		t.Error("expected an error registering the collectors twice")
+	}
+}
@@ -0,0 +1,114 @@
+package urlshort
+
+import (
+	"container/heap"
+	"sort"
+	"sync"
+)
+
+// DefaultMissCapacity is the number of paths a MissCounter tracks
+// when no other capacity is given.
+const DefaultMissCapacity = 1000
+
+// MissCounter counts the requests for paths that had no redirect,
+// to show which links people expect to exist. It tracks a bounded
+// number of paths: once full, recording a new path evicts the
+// least missed one, so paths missed only now and then may go
+// uncounted while frequently missed ones stay. It is safe for
+// concurrent use. See Options.Misses.
+type MissCounter struct {
+	mu       sync.Mutex
+	capacity int
+	paths    map[string]*missCount
+	order    missHeap
+}
+
+// missCount is the count of a path, and its position in the heap
+// of a MissCounter.
+type missCount struct {
+	path  string
+	count uint64
+	index int
+}
+
+// NewMissCounter returns a MissCounter tracking up to capacity
+// paths, or DefaultMissCapacity if capacity is not positive.
+func NewMissCounter(capacity int) *MissCounter {
+	if capacity <= 0 {
+		capacity = DefaultMissCapacity
+	}
+	return &MissCounter{capacity: capacity, paths: make(map[string]*missCount)}
+}
+
+// Record counts a miss of path.
+func (c *MissCounter) Record(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if m, ok := c.paths[path]; ok {
+		m.count++
+		heap.Fix(&c.order, m.index)
+		return
+	}
+	if len(c.order) >= c.capacity {
+		evicted := heap.Pop(&c.order).(*missCount)
+		delete(c.paths, evicted.path)
+	}
+	m := &missCount{path: path, count: 1}
+	c.paths[path] = m
+	heap.Push(&c.order, m)
+}
+
+// MissStat is the number of misses of a path.
+type MissStat struct {
+	Path   string `json:"path"`
+	Misses uint64 `json:"misses"`
+}
+
+// TopMisses returns the n most missed paths, most missed first.
+// Paths missed equally often are ordered by path. n <= 0 returns
+// every tracked path.
+func (c *MissCounter) TopMisses(n int) []MissStat {
+	c.mu.Lock()
+	stats := make([]MissStat, 0, len(c.order))
+	for _, m := range c.order {
+		stats = append(stats, MissStat{Path: m.path, Misses: m.count})
+	}
+	c.mu.Unlock()
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Misses != stats[j].Misses {
+			return stats[i].Misses > stats[j].Misses
+		}
+		return stats[i].Path < stats[j].Path
+	})
+	if n > 0 && len(stats) > n {
+		stats = stats[:n]
+	}
+	return stats
+}
+
+// missHeap is a min-heap of counts, least missed first.
+type missHeap []*missCount
+
+func (h missHeap) Len() int           { return len(h) }
+func (h missHeap) Less(i, j int) bool { return h[i].count < h[j].count }
+
+func (h missHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *missHeap) Push(x interface{}) {
+	m := x.(*missCount)
+	m.index = len(*h)
+	*h = append(*h, m)
+}
+
+func (h *missHeap) Pop() interface{} {
+	old := *h
+	m := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return m
+}
@@ -0,0 +1,46 @@
+package urlshort
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+func TestMissCounter(t *testing.T) {
+	mc := NewMissCounter(3)
+	h, err := MapHandlerWithOptions(map[string]string{"/a": "https://a.com"}, notFound, Options{Misses: mc, ReservedPaths: DefaultReservedPaths})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"/admin/links", "/x", "/x", "/x", "/y", "/y", "/z", "/a", "/w", "/w", "/x"} {
+		serve(h, http.MethodGet, path)
+	}
+	if got := fmt.Sprint(mc.TopMisses(0)); got != "[{/x 4} {/w 2} {/y 2}]" {
+		t.Errorf("TopMisses(0) = %s", got)
+	}
+	if got := mc.TopMisses(1); len(got) != 1 || got[0].Path != "/x" {
+		t.Errorf("TopMisses(1) = %v", got)
+	}
+}
+
+func TestMissCounterConcurrent(t *testing.T) {
+	mc := NewMissCounter(3)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				mc.Record(fmt.Sprint("/p", i, j))
+			}
+		}(i)
+	}
+	wg.Wait()
+	if n := len(mc.TopMisses(0)); n != 3 {
+		t.Errorf("kept %d paths, want the capacity of 3", n)
+	}
+	if c := NewMissCounter(0).capacity; c != DefaultMissCapacity {
+		t.Errorf("zero capacity became %d, want %d", c, DefaultMissCapacity)
+	}
+}
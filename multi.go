@@ -0,0 +1,117 @@
+package urlshort
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+)
+
+// MultiHandler will return an http.HandlerFunc that looks up the
+// request path in each of the sources in order and redirects to
+// the first match, so earlier sources take precedence over later
+// ones. If no source has the path, the fallback http.Handler will
+// be called instead.
+//
+// A source that returns an error is logged and skipped. Config
+// files can be used as sources via ParseYAML, ParseJSON and
+// friends:
+//
+//     yamlPaths, err := urlshort.ParseYAML(yml)
+//     ...
+//     handler := urlshort.MultiHandler(fallback, boltStore, urlshort.MapStore(yamlPaths))
+func MultiHandler(fallback http.Handler, sources ...Store) http.HandlerFunc {
+	return StoreHandler(ChainStore(sources...), fallback)
+}
+
+// ChainStore returns a Store that looks up paths in each of the
+// stores in order and returns the first hit. A store that returns
+// an error is logged and skipped, except for a *DisabledError,
+// which is returned so a link disabled in one store is not served
+// by a later one.
+func ChainStore(stores ...Store) Store {
+	return ChainStoreWithOptions(ChainOptions{}, stores...)
+}
+
+// ChainOptions configures a store returned by
+// ChainStoreWithOptions.
+type ChainOptions struct {
+	// StopOnError makes a lookup fail with the error of the first
+	// store that returns one, instead of skipping that store.
+	StopOnError bool
+}
+
+// ChainStoreWithOptions behaves like ChainStore but handles store
+// errors as configured by opts.
+func ChainStoreWithOptions(opts ChainOptions, stores ...Store) Store {
+	return &chainStore{stores: stores, stopOnError: opts.StopOnError}
+}
+
+// chainStore is a Store that returns the first hit among its
+// stores.
+type chainStore struct {
+	stores      []Store
+	stopOnError bool
+}
+
+func (c *chainStore) Lookup(path string) (string, bool, error) {
+	return c.LookupContext(context.Background(), path)
+}
+
+// LookupContext gives up on the remaining stores once ctx is done.
+func (c *chainStore) LookupContext(ctx context.Context, path string) (string, bool, error) {
+	return c.first(ctx, path, func(store Store) (string, bool, error) {
+		return lookupContext(ctx, store, path)
+	})
+}
+
+// Peek behaves like Lookup but peeks into the stores that are
+// Peekers.
+func (c *chainStore) Peek(path string) (string, bool, error) {
+	return c.first(context.Background(), path, func(store Store) (string, bool, error) {
+		return peek(store, path)
+	})
+}
+
+// Reveal behaves like Peek but reveals the path in the store
+// that has it, so a single-use redirect is not found even when a
+// later store has the same path.
+func (c *chainStore) Reveal(path string) (string, bool, error) {
+	var holder Store
+	_, _, err := c.first(context.Background(), path, func(store Store) (string, bool, error) {
+		url, ok, err := peek(store, path)
+		if ok {
+			holder = store
+		}
+		return url, ok, err
+	})
+	if err != nil || holder == nil {
+		return "", false, err
+	}
+	return Reveal(holder, path)
+}
+
+// first returns the first hit of lookup among the stores.
+func (c *chainStore) first(ctx context.Context, path string, lookup func(Store) (string, bool, error)) (string, bool, error) {
+	for _, store := range c.stores {
+		url, ok, err := lookup(store)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return "", false, ctxErr
+		}
+		var disabled *DisabledError
+		if errors.As(err, &disabled) {
+			return "", false, err
+		}
+		if err != nil {
+			if c.stopOnError {
+				return "", false, err
+			}
+			log.Printf("urlshort: lookup %s: %v", path, err)
+			continue
+		}
+		if ok {
+			return url, true, nil
+		}
+	}
+	return "", false, nil
+}
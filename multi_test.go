@@ -0,0 +1,76 @@
+package urlshort
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMultiHandler(t *testing.T) {
+	a := MapStore{"/x": "https://a.com"}
+	b := MapStore{"/x": "https://b.com", "/y": "https://y.com"}
+	expectRedirect(t, MultiHandler(notFound, a, b), "/x", http.StatusFound, "https://a.com")
+	expectRedirect(t, MultiHandler(notFound, b, a), "/x", http.StatusFound, "https://b.com")
+	expectRedirect(t, MultiHandler(notFound, errStore{}, b), "/y", http.StatusFound, "https://y.com")
+}
+
+func TestMultiHandlerContext(t *testing.T) {
+	h := MultiHandler(notFound, blockStore{}, MapStore{"/a": "https://a.com"})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/a", nil).WithContext(ctx))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("cancelled lookup = %d, want 404", w.Code)
+	}
+}
+
+func TestChainStore(t *testing.T) {
+	c := ChainStore(MapStore{"/a": "https://first.com"}, MapStore{"/a": "https://second.com", "/b": "https://b.com"})
+	if u, _, _ := c.Lookup("/a"); u != "https://first.com" {
+		t.Errorf("Lookup(/a) = %q, want the first store's", u)
+	}
+	if u, _, _ := c.Lookup("/b"); u != "https://b.com" {
+		t.Errorf("Lookup(/b) = %q, want the second store's", u)
+	}
+
+	if u, ok, err := ChainStore(errStore{}, MapStore{"/b": "https://b.com"}).Lookup("/b"); !ok || err != nil || u != "https://b.com" {
+		t.Errorf("Lookup past a failing store = %q, %v, %v", u, ok, err)
+	}
+	strict := ChainStoreWithOptions(ChainOptions{StopOnError: true}, errStore{}, MapStore{"/b": "https://b.com"})
+	if _, _, err := strict.Lookup("/b"); err == nil {
+		t.Error("StopOnError: expected the first store's error")
+	}
+}
+
+func TestChainStoreDisabled(t *testing.T) {
+	doc := "- path: /d\n  url: https://d.com\n  disabled: true\n"
+	entries, err := decodeYAMLEntries(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := Options{}.entryStore(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := StoreHandler(ChainStore(s, MapStore{"/d": "https://other.com"}), notFound)
+	expectStatus(t, h, "/d", http.StatusGone)
+}
+
+func TestChainStorePeek(t *testing.T) {
+	s := openTestBolt(t, BoltOptions{})
+	s.PutSingleUse("/c", "https://c.com")
+	if _, ok, _ := peek(ChainStore(MapStore{}, s), "/c"); !ok {
+		t.Fatal("Peek did not find the single-use link")
+	}
+	chain := ChainStore(MapStore{}, s, MapStore{"/c": "https://other.com"})
+	if _, ok, _ := Reveal(chain, "/c"); ok {
+		t.Error("Reveal found the single-use link, or the path of a later store")
+	}
+	if _, ok, _ := s.Lookup("/c"); !ok {
+		t.Error("Peek consumed the single-use link")
+	}
+}
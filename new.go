@@ -0,0 +1,87 @@
+package urlshort
+
+import "net/http"
+
+// Option configures the handler built by New.
+type Option func(*handlerConfig)
+
+// handlerConfig collects the settings of the handler built by New.
+type handlerConfig struct {
+	opts     Options
+	fallback http.Handler
+}
+
+// New will return an http.HandlerFunc that redirects the paths
+// found in store as configured by opts, such as
+//
+//     handler, err := urlshort.New(store,
+//         urlshort.WithStatus(http.StatusMovedPermanently),
+//         urlshort.WithPreserveQuery(),
+//         urlshort.WithFallback(mux),
+//     )
+//
+// Options are applied in order, so a later option overrides an
+// earlier one setting the same thing. Without WithFallback,
+// unknown paths are answered with 404. An error is returned if
+// the resulting configuration is invalid. New is equivalent to
+// StoreHandlerWithOptions, which remains available.
+func New(store Store, opts ...Option) (http.HandlerFunc, error) {
+	c := handlerConfig{fallback: http.NotFoundHandler()}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return StoreHandlerWithOptions(store, c.fallback, c.opts)
+}
+
+// WithOptions replaces the whole configuration with opts. It is
+// meant as the first option, for settings that have no Option of
+// their own.
+func WithOptions(opts Options) Option {
+	return func(c *handlerConfig) { c.opts = opts }
+}
+
+// WithFallback serves paths that are not found with fallback.
+func WithFallback(fallback http.Handler) Option {
+	return func(c *handlerConfig) { c.fallback = fallback }
+}
+
+// WithStatus redirects using status. See Options.Status.
+func WithStatus(status int) Option {
+	return func(c *handlerConfig) { c.opts.Status = status }
+}
+
+// WithPreserveQuery forwards the query string of requests to the
+// target. See Options.PreserveQuery.
+func WithPreserveQuery() Option {
+	return func(c *handlerConfig) { c.opts.PreserveQuery = true }
+}
+
+// WithCaseInsensitive matches paths regardless of case. See
+// Options.CaseInsensitive.
+func WithCaseInsensitive() Option {
+	return func(c *handlerConfig) { c.opts.CaseInsensitive = true }
+}
+
+// WithTrailingSlash matches paths with or without a trailing
+// slash. See Options.TrailingSlash.
+func WithTrailingSlash() Option {
+	return func(c *handlerConfig) { c.opts.TrailingSlash = true }
+}
+
+// WithAllowlist only redirects to targets on one of hosts. See
+// Options.AllowedHosts.
+func WithAllowlist(hosts ...string) Option {
+	return func(c *handlerConfig) { c.opts.AllowedHosts = hosts }
+}
+
+// WithOnRedirect calls hook before each redirect. See
+// Options.OnRedirect.
+func WithOnRedirect(hook func(r *http.Request, path, target string)) Option {
+	return func(c *handlerConfig) { c.opts.OnRedirect = hook }
+}
+
+// WithOnMiss calls hook for each path that is not found. See
+// Options.OnMiss.
+func WithOnMiss(hook func(r *http.Request)) Option {
+	return func(c *handlerConfig) { c.opts.OnMiss = hook }
+}
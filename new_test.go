@@ -0,0 +1,49 @@
+package urlshort
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	var missed, redirected int
+	h, err := New(MapStore{"/go": "https://go.dev/doc?a=1", "/evil": "https://evil.com"},
+		WithOptions(Options{CacheMaxAge: time.Minute}),
+		WithStatus(http.StatusMovedPermanently),
+		WithPreserveQuery(),
+		WithCaseInsensitive(),
+		WithTrailingSlash(),
+		WithAllowlist("go.dev"),
+		WithFallback(notFound),
+		WithOnRedirect(func(*http.Request, string, string) { redirected++ }),
+		WithOnMiss(func(*http.Request) { missed++ }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := serve(h, http.MethodGet, "/GO/?b=2")
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "https://go.dev/doc?a=1&b=2" {
+		t.Errorf("GET /GO/?b=2 = %d %q", w.Code, w.Header().Get("Location"))
+	}
+	if w.Header().Get("Cache-Control") == "" {
+		t.Error("WithOptions was overridden by later options")
+	}
+	expectStatus(t, h, "/evil", http.StatusNotFound)
+	if missed != 1 || redirected != 1 {
+		t.Errorf("hooks saw %d misses and %d redirects, want 1 each", missed, redirected)
+	}
+}
+
+func TestNewDefaults(t *testing.T) {
+	h, err := New(MapStore{"/a": "https://a.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectRedirect(t, h, "/a", http.StatusFound, "https://a.com")
+	expectStatus(t, h, "/x", http.StatusNotFound)
+
+	if _, err := New(MapStore{}, WithStatus(http.StatusOK)); err == nil {
+		t.Error("expected an error for status 200")
+	}
+}
@@ -0,0 +1,919 @@
+package urlshort
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"log"
+	"math"
+	"math/rand"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Options configures how a handler responds once a path has
+// been matched. The zero value redirects with http.StatusFound
+// and drops the incoming query string, which is the behavior of
+// the plain handler functions.
+type Options struct {
+	// Status is the redirect status code. Zero means
+	// http.StatusFound.
+	Status int
+
+	// PreserveQuery forwards the query string of the incoming
+	// request to the target URL. Parameters already present on
+	// the target take precedence over request parameters with
+	// the same key.
+	PreserveQuery bool
+
+	// TrailingSlash retries a missed lookup with the trailing
+	// slash of the path toggled, so /foo and /foo/ resolve to the
+	// same redirect. The root path / is never stripped.
+	TrailingSlash bool
+
+	// CaseInsensitive matches paths regardless of case by
+	// comparing lower-cased paths. Handlers built from a map or
+	// a config file lower-case their keys automatically; other
+	// stores must hold lower-cased keys themselves.
+	CaseInsensitive bool
+
+	// NormalizePaths matches paths by their Unicode NFC form, so
+	// a path written with combining characters matches the same
+	// path written with precomposed ones. Keys of a map or config
+	// file are also percent-decoded, so /caf%C3%A9 and /café are
+	// the same path; keys with invalid percent-encoding are logged
+	// and left out. Request paths arrive decoded already. Other
+	// stores must hold normalized keys themselves.
+	NormalizePaths bool
+
+	// CleanPaths canonicalizes request paths before they are
+	// looked up, as path.Clean does: repeated slashes are
+	// collapsed and . and .. segments resolved, so /a//b, /a/./b
+	// and /a/c/../b all match /a/b. A trailing slash is kept, so
+	// /a/ and /a stay distinct unless TrailingSlash is set. Keys
+	// of a map or config file are cleaned the same way; other
+	// stores must hold clean keys themselves.
+	CleanPaths bool
+
+	// Globs lets the paths of a map or config file use wildcards.
+	// A * matches any run of characters within a single path
+	// segment, and a segment of ** matches one or more segments,
+	// so /blog/* matches /blog/post but not /blog/post/comments,
+	// which /blog/** matches. This is the syntax of PrefixStore,
+	// except that a trailing /* there matches any depth for
+	// compatibility, and that globs redirect to their target as
+	// is rather than appending the rest of the path. Exact paths
+	// take precedence over globs, and longer globs over shorter
+	// ones. Without Globs, a * in a path is matched literally.
+	Globs bool
+
+	// AllowedHosts restricts redirect targets to URLs whose host
+	// is one of these hostnames. Empty allows any host.
+	AllowedHosts []string
+
+	// AllowedSchemes restricts redirect targets to URLs using one
+	// of these schemes, such as "http" and "https". Empty allows
+	// any scheme.
+	//
+	// Targets rejected by AllowedHosts or AllowedSchemes are
+	// dropped when a handler is built from a map or config file,
+	// and are treated as a miss when returned by any other Store.
+	AllowedSchemes []string
+
+	// ValidateTargets controls whether the targets of a map or
+	// config file must be absolute URLs. The default performs no
+	// validation.
+	ValidateTargets TargetValidation
+
+	// StrictFields makes the YAML, JSON and TOML handlers fail on
+	// entries with fields they do not know, such as a misspelled
+	// urls, instead of ignoring them.
+	StrictFields bool
+
+	// BaseURL is an absolute URL that relative targets of a map or
+	// config file, such as /landing/promo, are resolved against
+	// when the handler is built. Absolute targets are left alone,
+	// as are the targets returned by other stores. Empty leaves
+	// relative targets relative, redirecting on the same host.
+	BaseURL string
+
+	// Clock tells the current time, against which entry expiry
+	// and activation windows are checked. Nil means SystemClock.
+	Clock Clock
+
+	// Rand chooses between the targets of entries that split
+	// traffic. Nil means a source seeded from the current time.
+	Rand *rand.Rand
+
+	// NotFound, if set, is served instead of the fallback when a
+	// path is not found, so the fallback may be nil. Unless it
+	// writes a different status itself, the response is sent with
+	// http.StatusNotFound.
+	NotFound http.Handler
+
+	// UTM holds query parameters, such as utm_source and
+	// utm_medium, added to the target of every redirect. Entries
+	// of a map or config file may set their own with a utm field,
+	// which take precedence over these. Parameters the target
+	// already has are left alone unless OverrideUTM is set.
+	UTM map[string]string
+
+	// OverrideUTM lets UTM parameters replace parameters of the
+	// same name already present on the target.
+	OverrideUTM bool
+
+	// StripPrefix is removed from the request path before it is
+	// looked up, so a handler mounted under /r/ can resolve
+	// /r/promo using the path /promo. The prefix only matches
+	// whole path segments; requests outside of it are served by
+	// the fallback unchanged.
+	StripPrefix string
+
+	// GatewayTimeout responds with http.StatusGatewayTimeout when
+	// the request context is done before the lookup completes,
+	// for instance because of a deadline set by a timeout
+	// middleware. By default such requests fall through to the
+	// fallback like any other failed lookup.
+	GatewayTimeout bool
+
+	// GeoIP resolves client addresses to countries for entries of
+	// a map or config file that set per-country targets. Building
+	// a handler with such entries fails if it is nil.
+	GeoIP CountryResolver
+
+	// TrustForwardedFor takes the client address used by GeoIP
+	// from the X-Forwarded-For header instead of the connection.
+	// Only set it when the handler sits behind a proxy that sets
+	// the header, as clients can send any value they like.
+	TrustForwardedFor bool
+
+	// ReservedPaths are paths, along with every path below them,
+	// that are never redirected so that operational routes served
+	// by the fallback, such as an AdminHandler, cannot be
+	// shadowed. Entries of a map or config file for them are
+	// logged and left out. Nil reserves nothing; set it to
+	// DefaultReservedPaths when serving the handlers of this
+	// package next to the redirects.
+	ReservedPaths []string
+
+	// CacheMaxAge, if positive, is sent as the max-age of a
+	// Cache-Control header on permanent redirects (301 and 308)
+	// so browsers and CDNs can cache them.
+	CacheMaxAge time.Duration
+
+	// CacheTemporary sends the CacheMaxAge header on temporary
+	// redirects too.
+	CacheTemporary bool
+
+	// Fragment is a fragment, without the leading #, to give
+	// redirect targets, combined with any fragment a target
+	// already has as configured by FragmentMode. Browsers do not
+	// send the fragment of the requested URL, so it cannot be
+	// forwarded; most re-attach it themselves when the target has
+	// no fragment of its own.
+	Fragment string
+
+	// FragmentMode controls how Fragment is combined with the
+	// fragment of a target.
+	FragmentMode FragmentMode
+
+	// JSONResponse answers requests whose Accept header prefers
+	// application/json over text/html with a {"url": ...} JSON
+	// body and http.StatusOK instead of a redirect, so API clients
+	// can handle navigation themselves.
+	JSONResponse bool
+
+	// CORS, if set, adds cross-origin headers to the JSON
+	// responses of JSONResponse, which it requires, and answers
+	// CORS preflight requests for matched paths with
+	// http.StatusNoContent. Redirects are left alone.
+	CORS *CORS
+
+	// BlockLoops refuses to redirect to a target on the host of
+	// the handler that the handler would itself redirect again,
+	// answering with http.StatusLoopDetected instead, so a
+	// misconfigured entry cannot send clients round in circles.
+	// Relative targets are always on the host of the handler;
+	// absolute ones are when their host is one of OwnHosts.
+	BlockLoops bool
+
+	// OwnHosts are the hostnames the handler is served under, for
+	// BlockLoops. Empty means the host the request was sent to.
+	OwnHosts []string
+
+	// Headers are set on every redirect response, for instance a
+	// Referrer-Policy. Entries of a map or config file may set
+	// their own with a headers field, which take precedence over
+	// these. Location cannot be set this way.
+	Headers map[string]string
+
+	// RedirectPage, if set, renders the body of redirects to GET
+	// requests instead of the short link to the target written by
+	// http.Redirect, so browsers that do not follow the redirect
+	// right away show a branded page. It is executed with a value
+	// whose URL and Path fields hold the target and the matched
+	// path. If it fails, the error is logged and the default body
+	// is written instead.
+	RedirectPage *template.Template
+
+	// NoRedirectBody sends redirects without a body. It takes
+	// precedence over RedirectPage. The Location header and status
+	// are the same whatever the body.
+	NoRedirectBody bool
+
+	// Cookie, if set, is sent with every redirect, for instance to
+	// let the pages behind short links attribute visits to a
+	// campaign.
+	Cookie *RedirectCookie
+
+	// OnRedirect, if set, is called with the matched path and the
+	// final target just before each redirect is written, so it may
+	// still set response headers such as cookies.
+	OnRedirect func(r *http.Request, path, target string)
+
+	// DisabledFallback passes requests for disabled entries to the
+	// fallback, with a Rejection in the request context, instead
+	// of answering them with 410 Gone.
+	DisabledFallback bool
+
+	// Misses, if set, counts the request paths that did not match,
+	// before they are passed to the fallback. Reserved paths are
+	// not counted.
+	Misses *MissCounter
+
+	// OnMiss, if set, is called before a request that did not
+	// match is passed to the fallback.
+	//
+	// A panic in OnRedirect or OnMiss is recovered and logged so
+	// that the response is still written.
+	OnMiss func(r *http.Request)
+}
+
+// RedirectCookie describes a cookie set on redirects. See
+// Options.Cookie.
+type RedirectCookie struct {
+	// Name is the name of the cookie. It must be set.
+	Name string
+
+	// Value is the value of the cookie. The placeholders {path}
+	// and {campaign} are replaced by the matched path and by the
+	// utm_campaign parameter of the target, which is empty if the
+	// target has none.
+	Value string
+
+	// MaxAge is how long the cookie is kept. Zero makes it a
+	// session cookie.
+	MaxAge time.Duration
+
+	// Domain and Path scope the cookie. An empty Path means "/".
+	Domain string
+	Path   string
+
+	SameSite http.SameSite
+	Secure   bool
+	HttpOnly bool
+}
+
+// cookie returns the cookie c describes for a redirect of path to
+// target.
+func (c *RedirectCookie) cookie(path, target string) *http.Cookie {
+	var campaign string
+	if u, err := url.Parse(target); err == nil {
+		campaign = u.Query().Get("utm_campaign")
+	}
+	value := strings.NewReplacer("{path}", path, "{campaign}", campaign).Replace(c.Value)
+	cookiePath := c.Path
+	if cookiePath == "" {
+		cookiePath = "/"
+	}
+	return &http.Cookie{
+		Name:     c.Name,
+		Value:    value,
+		MaxAge:   int(c.MaxAge / time.Second),
+		Domain:   c.Domain,
+		Path:     cookiePath,
+		SameSite: c.SameSite,
+		Secure:   c.Secure,
+		HttpOnly: c.HttpOnly,
+	}
+}
+
+// FragmentMode selects how Options.Fragment is combined with the
+// fragment of a redirect target.
+type FragmentMode int
+
+const (
+	// KeepTargetFragment keeps the fragment of targets that have
+	// one and gives Fragment to the others.
+	KeepTargetFragment FragmentMode = iota
+	// ReplaceTargetFragment replaces the fragment of every target
+	// with Fragment, removing it if Fragment is empty.
+	ReplaceTargetFragment
+	// MergeTargetFragment appends Fragment to the fragment of
+	// targets that have one, separated by &, as used by fragments
+	// carrying parameters.
+	MergeTargetFragment
+)
+
+// TargetValidation selects how handlers built from a map or
+// config file deal with targets that are not absolute URLs with
+// a scheme and a host.
+type TargetValidation int
+
+const (
+	// AcceptAllTargets serves every target as is.
+	AcceptAllTargets TargetValidation = iota
+	// RejectInvalidTargets fails to build the handler, returning
+	// an error that lists every invalid entry.
+	RejectInvalidTargets
+	// SkipInvalidTargets logs and leaves out invalid entries.
+	SkipInvalidTargets
+)
+
+// withDefaults fills in unset fields of opts and validates the
+// result.
+func (opts Options) withDefaults() (Options, error) {
+	if opts.Status == 0 {
+		opts.Status = http.StatusFound
+	}
+	if err := checkRedirectStatus(opts.Status); err != nil {
+		return opts, err
+	}
+	if opts.BaseURL != "" {
+		if err := checkAbsoluteURL(opts.BaseURL); err != nil {
+			return opts, fmt.Errorf("base url: %s", err)
+		}
+	}
+	if err := checkHeaders(opts.Headers); err != nil {
+		return opts, err
+	}
+	if opts.Cookie != nil && opts.Cookie.Name == "" {
+		return opts, fmt.Errorf("redirect cookie has no name")
+	}
+	if opts.CORS != nil && !opts.JSONResponse {
+		return opts, fmt.Errorf("CORS requires JSONResponse")
+	}
+	return opts, nil
+}
+
+// entryStore builds a Store from entries whose keys are
+// normalized the same way opts normalizes request paths.
+// Targets that opts does not allow are logged and left out, and
+// invalid targets are handled as configured by ValidateTargets.
+func (opts Options) entryStore(entries []entry) (*entryStore, error) {
+	if opts.BaseURL != "" {
+		base, err := url.Parse(opts.BaseURL)
+		if err != nil {
+			return nil, fmt.Errorf("base url: %s", err)
+		}
+		resolved := make([]entry, len(entries))
+		for i, e := range entries {
+			if resolved[i], err = e.resolve(base); err != nil {
+				return nil, err
+			}
+		}
+		entries = resolved
+	}
+	if opts.ValidateTargets == RejectInvalidTargets {
+		if err := checkTargets(entries); err != nil {
+			return nil, err
+		}
+	}
+
+	rng := opts.Rand
+	if rng == nil {
+		rng = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	store := &entryStore{
+		redirects:   make(map[string]redirect, len(entries)),
+		now:         opts.now(),
+		rng:         rng,
+		overrideUTM: opts.OverrideUTM,
+		geo:         geoLookup{resolver: opts.GeoIP, trustForwardedFor: opts.TrustForwardedFor},
+	}
+next:
+	for _, e := range entries {
+		if isReserved(e.Path, opts.ReservedPaths) {
+			log.Printf("urlshort: skipping %s: path is reserved", e.Path)
+			continue
+		}
+		for _, url := range e.urls() {
+			if !opts.allowed(url) {
+				log.Printf("urlshort: skipping %s: target %s is not allowed", e.Path, url)
+				continue next
+			}
+			if opts.ValidateTargets == SkipInvalidTargets {
+				if err := checkAbsoluteURL(url); err != nil {
+					log.Printf("urlshort: skipping %s: %v", e.Path, err)
+					continue next
+				}
+			}
+		}
+		r, err := parseEntry(e)
+		if err != nil {
+			return nil, err
+		}
+		if len(r.countries) > 0 && opts.GeoIP == nil {
+			return nil, fmt.Errorf("countries for %s require the GeoIP option", e.Path)
+		}
+		r.utm = mergeParams(opts.UTM, e.UTM)
+		path := e.Path
+		if opts.NormalizePaths {
+			unescaped, err := url.PathUnescape(path)
+			if err != nil {
+				log.Printf("urlshort: skipping %s: %v", e.Path, err)
+				continue
+			}
+			path = norm.NFC.String(unescaped)
+		}
+		if opts.CleanPaths {
+			path = cleanPath(path)
+		}
+		if opts.CaseInsensitive {
+			path = strings.ToLower(path)
+		}
+		if opts.Globs && isGlob(path) {
+			if _, dup := store.redirects[path]; !dup {
+				g, err := parseGlob(path)
+				if err != nil {
+					return nil, err
+				}
+				store.globs = append(store.globs, g)
+			}
+		}
+		store.redirects[path] = r
+	}
+	sortGlobs(store.globs)
+	return store, nil
+}
+
+// now returns the clock configured by opts.
+func (opts Options) now() func() time.Time {
+	if opts.Clock != nil {
+		return opts.Clock.Now
+	}
+	return SystemClock.Now
+}
+
+// restrictsTargets reports whether opts limits which targets may
+// be redirected to.
+func (opts Options) restrictsTargets() bool {
+	return len(opts.AllowedHosts) > 0 || len(opts.AllowedSchemes) > 0
+}
+
+// allowed reports whether target passes the AllowedHosts and
+// AllowedSchemes restrictions of opts.
+func (opts Options) allowed(target string) bool {
+	if !opts.restrictsTargets() {
+		return true
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return false
+	}
+	if len(opts.AllowedSchemes) > 0 && !containsFold(opts.AllowedSchemes, u.Scheme) {
+		return false
+	}
+	if len(opts.AllowedHosts) > 0 && !containsFold(opts.AllowedHosts, u.Hostname()) {
+		return false
+	}
+	return true
+}
+
+// containsFold reports whether list contains s, ignoring case.
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// missHandler returns the handler to serve when a path is not
+// found: NotFound if set, otherwise fallback, otherwise a plain
+// 404 page.
+func (opts Options) missHandler(fallback http.Handler) http.Handler {
+	if opts.NotFound != nil {
+		return notFoundHandler(opts.NotFound)
+	}
+	if fallback == nil {
+		return http.NotFoundHandler()
+	}
+	return fallback
+}
+
+// notFoundHandler serves h, defaulting the response status to
+// http.StatusNotFound.
+func notFoundHandler(h http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		nw := &notFoundWriter{ResponseWriter: w}
+		h.ServeHTTP(nw, r)
+		if !nw.wroteHeader {
+			nw.WriteHeader(http.StatusNotFound)
+		}
+	}
+}
+
+// notFoundWriter is an http.ResponseWriter whose implicit status
+// is http.StatusNotFound instead of http.StatusOK.
+type notFoundWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *notFoundWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *notFoundWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusNotFound)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// requestPath returns the path of r to look up, with StripPrefix
+// removed. It reports false if the path is reserved or outside of
+// the prefix.
+func (opts Options) requestPath(r *http.Request) (string, bool) {
+	path := r.URL.Path
+	if opts.CleanPaths {
+		path = cleanPath(path)
+	}
+	if isReserved(path, opts.ReservedPaths) {
+		return "", false
+	}
+	prefix := strings.TrimSuffix(opts.StripPrefix, "/")
+	if prefix == "" {
+		return path, true
+	}
+	if path == prefix {
+		return "/", true
+	}
+	if !strings.HasPrefix(path, prefix+"/") {
+		return "", false
+	}
+	return strings.TrimPrefix(path, prefix), true
+}
+
+// lookup resolves path in store on behalf of r, applying the path
+// matching rules configured by opts. It also returns the response
+// to redirect with, which entries of a map or config file may
+// customize. Unless consume is set, stores that are Peekers are
+// looked into with Reveal, so single-use redirects are neither
+// used up nor found and no hits are counted.
+func (opts Options) lookup(r *http.Request, store Store, path string, consume bool) (string, response, bool, error) {
+	if opts.CleanPaths {
+		path = cleanPath(path)
+	}
+	if opts.NormalizePaths {
+		path = norm.NFC.String(path)
+	}
+	if opts.CaseInsensitive {
+		path = strings.ToLower(path)
+	}
+	find := func(path string) (string, bool, error) {
+		if _, isPeeker := store.(Peeker); isPeeker && !consume {
+			return Reveal(store, path)
+		}
+		return lookupRequest(r, store, path)
+	}
+	url, ok, err := find(path)
+	if !ok && err == nil && opts.TrailingSlash {
+		if alt, toggled := toggleTrailingSlash(path); toggled {
+			path = alt
+			url, ok, err = find(path)
+		}
+	}
+	var resp response
+	if rs, isResponseStore := store.(responseStore); ok && isResponseStore {
+		resp = rs.redirectResponse(path)
+	}
+	if resp.status == 0 {
+		resp.status = opts.Status
+	}
+	return url, resp, ok, err
+}
+
+// redirectsAgain reports whether target, the target of a request
+// r to a handler looking up paths in store, would be redirected
+// by the same handler.
+func (opts Options) redirectsAgain(r *http.Request, store Store, target string) bool {
+	u, err := url.Parse(target)
+	if err != nil {
+		return false
+	}
+	if u.Host != "" {
+		if u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https" {
+			return false
+		}
+		own := opts.OwnHosts
+		if len(own) == 0 {
+			own = []string{stripPort(r.Host)}
+		}
+		if !containsFold(own, u.Hostname()) {
+			return false
+		}
+	}
+	next := r.Clone(r.Context())
+	next.URL = r.URL.ResolveReference(u)
+	path, ok := opts.requestPath(next)
+	if !ok {
+		return false
+	}
+	_, _, ok, err = opts.lookup(next, store, path, false)
+	return ok && err == nil
+}
+
+// stripPort removes the port, if any, from hostport.
+func stripPort(hostport string) string {
+	if host, _, err := net.SplitHostPort(hostport); err == nil {
+		return host
+	}
+	return hostport
+}
+
+// cleanPath returns the canonical form of path as computed by
+// path.Clean, but keeping a trailing slash.
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	clean := path.Clean(p)
+	if strings.HasSuffix(p, "/") && clean != "/" {
+		clean += "/"
+	}
+	return clean
+}
+
+// toggleTrailingSlash adds a trailing slash to path or removes
+// the one it has. It reports false for paths that cannot be
+// toggled, such as the root path.
+func toggleTrailingSlash(path string) (string, bool) {
+	if path == "" || path == "/" {
+		return path, false
+	}
+	if strings.HasSuffix(path, "/") {
+		return strings.TrimSuffix(path, "/"), true
+	}
+	return path + "/", true
+}
+
+// redirect writes the redirect response resp for the target path
+// resolved to. HEAD requests get the same status and headers as
+// GET but no body.
+func (opts Options) redirect(w http.ResponseWriter, r *http.Request, resp response, path, target string) {
+	target = opts.target(r, target)
+	if opts.OnRedirect != nil {
+		safeHook("OnRedirect", func() { opts.OnRedirect(r, path, target) })
+	}
+	for _, headers := range []map[string]string{opts.Headers, resp.headers} {
+		for name, value := range headers {
+			w.Header().Set(name, value)
+		}
+	}
+	if opts.Cookie != nil {
+		http.SetCookie(w, opts.Cookie.cookie(path, target))
+	}
+	if opts.JSONResponse {
+		w.Header().Add("Vary", "Accept")
+		if prefersJSON(r) {
+			if opts.CORS != nil {
+				opts.CORS.allowOrigin(w, r)
+			}
+			writeJSON(w, http.StatusOK, jsonRedirect{URL: target})
+			return
+		}
+	}
+	if opts.CacheMaxAge > 0 && (opts.CacheTemporary || isPermanent(resp.status)) {
+		w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", int64(opts.CacheMaxAge/time.Second)))
+	}
+	if r.Method == http.MethodHead || opts.NoRedirectBody {
+		w = headWriter{w}
+	} else if opts.RedirectPage != nil && r.Method == http.MethodGet {
+		if page, ok := opts.redirectPage(path, target); ok {
+			// http.Redirect leaves the body alone when the
+			// response already has a Content-Type.
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			http.Redirect(w, r, target, resp.status)
+			w.Write(page)
+			return
+		}
+	}
+	http.Redirect(w, r, target, resp.status)
+}
+
+// target returns the URL a request r is redirected to when its
+// path resolves to target, with the UTM parameters, query and
+// fragment configured by opts applied.
+func (opts Options) target(r *http.Request, target string) string {
+	if len(opts.UTM) > 0 {
+		target = addParams(target, opts.UTM, opts.OverrideUTM)
+	}
+	if opts.PreserveQuery {
+		target = mergeQuery(target, r.URL.RawQuery)
+	}
+	if opts.Fragment != "" || opts.FragmentMode == ReplaceTargetFragment {
+		target = setFragment(target, opts.Fragment, opts.FragmentMode)
+	}
+	return target
+}
+
+// redirectPage renders the RedirectPage of opts for a redirect of
+// path to target. It reports false if rendering fails.
+func (opts Options) redirectPage(path, target string) ([]byte, bool) {
+	var buf bytes.Buffer
+	data := struct{ URL, Path string }{URL: target, Path: path}
+	if err := opts.RedirectPage.Execute(&buf, data); err != nil {
+		log.Printf("urlshort: render redirect page for %s: %v", path, err)
+		return nil, false
+	}
+	return buf.Bytes(), true
+}
+
+// checkHeaders reports an error if headers sets a header that
+// redirects must control themselves.
+func checkHeaders(headers map[string]string) error {
+	for name := range headers {
+		if http.CanonicalHeaderKey(name) == "Location" {
+			return fmt.Errorf("header %s cannot be set", name)
+		}
+	}
+	return nil
+}
+
+// miss serves a request that did not match with fallback.
+func (opts Options) miss(w http.ResponseWriter, r *http.Request, fallback http.Handler) {
+	if opts.Misses != nil && !isReserved(r.URL.Path, opts.ReservedPaths) {
+		opts.Misses.Record(r.URL.Path)
+	}
+	if opts.OnMiss != nil {
+		safeHook("OnMiss", func() { opts.OnMiss(r) })
+	}
+	fallback.ServeHTTP(w, r)
+}
+
+// gone answers a request for a disabled path.
+func gone(w http.ResponseWriter, err *DisabledError) {
+	msg := err.Message
+	if msg == "" {
+		msg = "this link is disabled"
+	}
+	http.Error(w, msg, http.StatusGone)
+}
+
+// safeHook calls hook, recovering and logging any panic.
+func safeHook(name string, hook func()) {
+	defer func() {
+		if err := recover(); err != nil {
+			log.Printf("urlshort: %s panicked: %v", name, err)
+		}
+	}()
+	hook()
+}
+
+// jsonRedirect is the body of a redirect answered with JSON.
+type jsonRedirect struct {
+	URL string `json:"url"`
+}
+
+// prefersJSON reports whether the Accept header of r ranks
+// application/json above text/html.
+func prefersJSON(r *http.Request) bool {
+	var jsonQ, htmlQ float64
+	for _, accept := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(accept, ",") {
+			mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+			if err != nil {
+				continue
+			}
+			q := 1.0
+			if v, ok := params["q"]; ok {
+				if q, err = strconv.ParseFloat(v, 64); err != nil {
+					continue
+				}
+			}
+			switch mediaType {
+			case "application/json":
+				jsonQ = math.Max(jsonQ, q)
+			case "text/html":
+				htmlQ = math.Max(htmlQ, q)
+			}
+		}
+	}
+	return jsonQ > 0 && jsonQ > htmlQ
+}
+
+// isPermanent reports whether status is a permanent redirect.
+func isPermanent(status int) bool {
+	return status == http.StatusMovedPermanently || status == http.StatusPermanentRedirect
+}
+
+// headWriter is an http.ResponseWriter that discards the body of
+// the response.
+type headWriter struct {
+	http.ResponseWriter
+}
+
+func (w headWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+// mergeQuery appends the parameters in rawQuery to the query of
+// target, skipping any key target already defines. The target's
+// own query is left untouched.
+func mergeQuery(target, rawQuery string) string {
+	if rawQuery == "" {
+		return target
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return target
+	}
+	existing := u.Query()
+	extra, _ := url.ParseQuery(rawQuery)
+	for key := range extra {
+		if _, ok := existing[key]; ok {
+			delete(extra, key)
+		}
+	}
+	if len(extra) == 0 {
+		return target
+	}
+	if u.RawQuery != "" {
+		u.RawQuery += "&"
+	}
+	u.RawQuery += extra.Encode()
+	return u.String()
+}
+
+// addParams sets the query parameters in params on target. Keys
+// target already defines are only replaced if override is set.
+func addParams(target string, params map[string]string, override bool) string {
+	u, err := url.Parse(target)
+	if err != nil {
+		return target
+	}
+	query := u.Query()
+	changed := false
+	for key, value := range params {
+		if _, ok := query[key]; ok && !override {
+			continue
+		}
+		query.Set(key, value)
+		changed = true
+	}
+	if !changed {
+		return target
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// mergeParams returns the parameters of base overlaid with those
+// of extra, or nil if both are empty.
+func mergeParams(base, extra map[string]string) map[string]string {
+	if len(base) == 0 && len(extra) == 0 {
+		return nil
+	}
+	params := make(map[string]string, len(base)+len(extra))
+	for key, value := range base {
+		params[key] = value
+	}
+	for key, value := range extra {
+		params[key] = value
+	}
+	return params
+}
+
+// setFragment combines fragment with the fragment of target as
+// described by mode.
+func setFragment(target, fragment string, mode FragmentMode) string {
+	u, err := url.Parse(target)
+	if err != nil {
+		return target
+	}
+	switch {
+	case mode == ReplaceTargetFragment || u.Fragment == "":
+		u.Fragment = fragment
+	case mode == MergeTargetFragment && fragment != "":
+		u.Fragment += "&" + fragment
+	default:
+		return target
+	}
+	u.RawFragment = ""
+	return u.String()
+}
@@ -0,0 +1,414 @@
+package urlshort
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPreserveQuery(t *testing.T) {
+	m := map[string]string{"/a": "https://a.com/x?ref=cfg&k=1", "/b": "https://b.com"}
+	h, err := MapHandlerWithOptions(m, notFound, Options{PreserveQuery: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectRedirect(t, h, "/a?ref=tw&z=2", http.StatusFound, "https://a.com/x?ref=cfg&k=1&z=2")
+	expectRedirect(t, h, "/b?ref=tw", http.StatusFound, "https://b.com?ref=tw")
+
+	expectRedirect(t, MapHandler(m, notFound), "/b?ref=tw", http.StatusFound, "https://b.com")
+}
+
+func TestTrailingSlash(t *testing.T) {
+	m := map[string]string{"/foo": "https://f.com", "/bar/": "https://b.com"}
+	h, err := MapHandlerWithOptions(m, notFound, Options{TrailingSlash: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"/foo", "/foo/", "/bar", "/bar/"} {
+		expectStatus(t, h, path, http.StatusFound)
+	}
+	expectStatus(t, h, "/", http.StatusNotFound)
+
+	expectStatus(t, MapHandler(m, notFound), "/foo/", http.StatusNotFound)
+}
+
+func TestCaseInsensitive(t *testing.T) {
+	m := map[string]string{"/BlackFriday": "https://f.com"}
+	h, err := MapHandlerWithOptions(m, notFound, Options{CaseInsensitive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"/blackfriday", "/BLACKFRIDAY", "/BlackFriday"} {
+		expectStatus(t, h, path, http.StatusFound)
+	}
+
+	expectStatus(t, MapHandler(m, notFound), "/blackfriday", http.StatusNotFound)
+}
+
+func TestAllowedTargets(t *testing.T) {
+	m := map[string]string{"/ok": "https://good.com/x", "/bad": "https://evil.com", "/js": "javascript:alert(1)"}
+	h, err := MapHandlerWithOptions(m, notFound, Options{AllowedHosts: []string{"good.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, h, "/ok", http.StatusFound)
+	expectStatus(t, h, "/bad", http.StatusNotFound)
+	expectStatus(t, h, "/js", http.StatusNotFound)
+
+	h, _ = MapHandlerWithOptions(m, notFound, Options{AllowedSchemes: []string{"http", "https"}})
+	expectStatus(t, h, "/bad", http.StatusFound)
+	expectStatus(t, h, "/js", http.StatusNotFound)
+
+	h, _ = StoreHandlerWithOptions(MapStore(m), notFound, Options{AllowedSchemes: []string{"https"}})
+	expectStatus(t, h, "/js", http.StatusNotFound)
+}
+
+func TestValidateTargets(t *testing.T) {
+	m := map[string]string{"/ok": "https://good.com", "/e": "", "/r": "/rel", "/s": "example.com/x"}
+	_, err := MapHandlerWithOptions(m, notFound, Options{ValidateTargets: RejectInvalidTargets})
+	if err == nil || !strings.Contains(err.Error(), "/e:") || !strings.Contains(err.Error(), "/s:") {
+		t.Fatalf("RejectInvalidTargets error = %v, want one naming /e and /s", err)
+	}
+
+	h, err := MapHandlerWithOptions(m, notFound, Options{ValidateTargets: SkipInvalidTargets})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, h, "/ok", http.StatusFound)
+	expectStatus(t, h, "/r", http.StatusNotFound)
+}
+
+func TestNotFoundOption(t *testing.T) {
+	branded := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("branded"))
+	})
+	h, err := MapHandlerWithOptions(map[string]string{"/a": "https://a.com"}, nil, Options{NotFound: branded})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := serve(h, http.MethodGet, "/zzz"); w.Code != http.StatusNotFound || w.Body.String() != "branded" {
+		t.Errorf("GET /zzz = %d %q, want 404 branded", w.Code, w.Body)
+	}
+	expectStatus(t, h, "/a", http.StatusFound)
+
+	h, _ = MapHandlerWithOptions(nil, nil, Options{})
+	expectStatus(t, h, "/a", http.StatusNotFound)
+}
+
+func TestUTM(t *testing.T) {
+	doc := []byte("- path: /a\n  url: https://a.com/?utm_source=orig&x=1\n  utm: {utm_campaign: spring}\n" +
+		"- path: /b\n  url: https://b.com/\n")
+	h, err := YAMLHandlerWithOptions(doc, notFound, Options{UTM: map[string]string{"utm_source": "short", "utm_campaign": "global"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectRedirect(t, h, "/a", http.StatusFound, "https://a.com/?utm_campaign=spring&utm_source=orig&x=1")
+	expectRedirect(t, h, "/b", http.StatusFound, "https://b.com/?utm_campaign=global&utm_source=short")
+
+	h, _ = YAMLHandlerWithOptions(doc, notFound, Options{UTM: map[string]string{"utm_source": "short"}, OverrideUTM: true})
+	expectRedirect(t, h, "/a", http.StatusFound, "https://a.com/?utm_campaign=spring&utm_source=short&x=1")
+
+	h, _ = StoreHandlerWithOptions(MapStore{"/c": "https://c.com"}, notFound, Options{UTM: map[string]string{"utm_medium": "qr"}})
+	expectRedirect(t, h, "/c", http.StatusFound, "https://c.com?utm_medium=qr")
+}
+
+func TestStripPrefix(t *testing.T) {
+	m := map[string]string{"/promo": "https://p.com", "/": "https://root.com"}
+	h, err := MapHandlerWithOptions(m, notFound, Options{StripPrefix: "/r/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectRedirect(t, h, "/r/promo", http.StatusFound, "https://p.com")
+	expectRedirect(t, h, "/r", http.StatusFound, "https://root.com")
+	for _, path := range []string{"/promo", "/rpromo", "/r/nope"} {
+		expectStatus(t, h, path, http.StatusNotFound)
+	}
+}
+
+func TestReservedPaths(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	m := map[string]string{"/health": "https://evil.com", "/admin/x": "https://evil.com", "/a": "https://a.com"}
+
+	h := MapHandler(m, mux)
+	expectStatus(t, h, "/health", http.StatusFound)
+	expectStatus(t, h, "/admin/x", http.StatusFound)
+
+	h, _ = MapHandlerWithOptions(m, mux, Options{ReservedPaths: DefaultReservedPaths})
+	if w := serve(h, http.MethodGet, "/health"); w.Code != http.StatusOK || w.Body.String() != "ok" {
+		t.Errorf("GET /health = %d %q, want the mux's response", w.Code, w.Body)
+	}
+	expectStatus(t, h, "/admin/x", http.StatusNotFound)
+	expectStatus(t, h, "/a", http.StatusFound)
+
+	h, _ = StoreHandlerWithOptions(MapStore{"/health": "https://evil.com"}, mux, Options{ReservedPaths: DefaultReservedPaths})
+	expectStatus(t, h, "/health", http.StatusOK)
+
+	h, _ = MapHandlerWithOptions(map[string]string{"/health": "https://x.com", "/go": "https://go.com"}, mux, Options{ReservedPaths: []string{"/go"}})
+	expectStatus(t, h, "/health", http.StatusFound)
+	expectStatus(t, h, "/go", http.StatusNotFound)
+}
+
+func TestCacheMaxAge(t *testing.T) {
+	m := map[string]string{"/a": "https://a.com"}
+	for _, tc := range []struct {
+		opts Options
+		want string
+	}{
+		{Options{Status: http.StatusMovedPermanently, CacheMaxAge: time.Hour}, "max-age=3600"},
+		{Options{CacheMaxAge: time.Hour}, ""},
+		{Options{CacheMaxAge: time.Minute, CacheTemporary: true}, "max-age=60"},
+	} {
+		h, err := MapHandlerWithOptions(m, notFound, tc.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := serve(h, http.MethodGet, "/a").Header().Get("Cache-Control"); got != tc.want {
+			t.Errorf("%+v: Cache-Control = %q, want %q", tc.opts, got, tc.want)
+		}
+	}
+}
+
+func TestFragment(t *testing.T) {
+	m := map[string]string{"/with": "https://a.com/kb#setup", "/without": "https://a.com/kb"}
+	for _, tc := range []struct {
+		mode          FragmentMode
+		fragment      string
+		with, without string
+	}{
+		{KeepTargetFragment, "install", "https://a.com/kb#setup", "https://a.com/kb#install"},
+		{ReplaceTargetFragment, "install", "https://a.com/kb#install", "https://a.com/kb#install"},
+		{ReplaceTargetFragment, "", "https://a.com/kb", "https://a.com/kb"},
+		{MergeTargetFragment, "x=1", "https://a.com/kb#setup&x=1", "https://a.com/kb#x=1"},
+		{KeepTargetFragment, "", "https://a.com/kb#setup", "https://a.com/kb"},
+	} {
+		h, err := MapHandlerWithOptions(m, notFound, Options{Fragment: tc.fragment, FragmentMode: tc.mode})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectRedirect(t, h, "/with", http.StatusFound, tc.with)
+		expectRedirect(t, h, "/without", http.StatusFound, tc.without)
+	}
+}
+
+func TestJSONResponse(t *testing.T) {
+	m := map[string]string{"/a": "https://a.com"}
+	h, err := MapHandlerWithOptions(m, notFound, Options{JSONResponse: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for accept, wantJSON := range map[string]bool{
+		"application/json":                          true,
+		"text/html,application/xhtml+xml,*/*;q=0.8": false,
+		"":                                  false,
+		"text/html;q=0.5, application/json": true,
+		"application/json;q=0.1, text/html": false,
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/a", nil)
+		r.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if wantJSON && (w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != `{"url":"https://a.com"}`) {
+			t.Errorf("Accept %q = %d %q, want a JSON body", accept, w.Code, w.Body)
+		}
+		if !wantJSON && w.Code != http.StatusFound {
+			t.Errorf("Accept %q = %d, want 302", accept, w.Code)
+		}
+		if w.Header().Get("Vary") != "Accept" {
+			t.Errorf("Accept %q: Vary = %q, want Accept", accept, w.Header().Get("Vary"))
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/a", nil)
+	r.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+	MapHandler(m, notFound).ServeHTTP(w, r)
+	if w.Code != http.StatusFound {
+		t.Errorf("JSON requested without JSONResponse = %d, want 302", w.Code)
+	}
+}
+
+func TestHooks(t *testing.T) {
+	var got []string
+	h, err := MapHandlerWithOptions(map[string]string{"/a": "https://a.com"}, notFound, Options{
+		UTM: map[string]string{"s": "1"},
+		OnRedirect: func(r *http.Request, path, target string) {
+			got = append(got, r.Method+" "+path+" "+target)
+			panic("hooks must not break redirects")
+		},
+		OnMiss: func(r *http.Request) {
+			got = append(got, "miss "+r.URL.Path)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, h, "/a", http.StatusFound)
+	expectStatus(t, h, "/b", http.StatusNotFound)
+	if s := strings.Join(got, "|"); s != "GET /a https://a.com?s=1|miss /b" {
+		t.Errorf("hooks saw %q", s)
+	}
+}
+
+func TestNormalizePaths(t *testing.T) {
+	m := map[string]string{"/caf%C3%A9": "https://c.com", "/naïve": "https://n.com", "/bad%zz": "https://b.com"}
+	h, err := MapHandlerWithOptions(m, notFound, Options{NormalizePaths: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"/caf%C3%A9", "/café", "/cafe%CC%81", "/na%C3%AFve", "/nai%CC%88ve"} {
+		expectStatus(t, h, path, http.StatusFound)
+	}
+	expectStatus(t, h, "/bad%25zz", http.StatusNotFound)
+
+	expectStatus(t, MapHandler(m, notFound), "/café", http.StatusNotFound)
+}
+
+func TestCleanPaths(t *testing.T) {
+	m := map[string]string{"/a/b": "https://ab.com", "/a/b/": "https://ab-slash.com", "/": "https://root.com", "/x//y/../z": "https://xz.com"}
+	h, err := MapHandlerWithOptions(m, notFound, Options{CleanPaths: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(h http.Handler, path string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.URL.Path = path
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	for path, want := range map[string]string{
+		"/a//b":     "https://ab.com",
+		"/a/./b":    "https://ab.com",
+		"/a/c/../b": "https://ab.com",
+		"/a/b//":    "https://ab-slash.com",
+		"/../a/b":   "https://ab.com",
+		"//":        "https://root.com",
+		"/a/..":     "https://root.com",
+		"/x/z":      "https://xz.com",
+		"/a/b/c/..": "https://ab.com",
+	} {
+		if got := get(h, path).Header().Get("Location"); got != want {
+			t.Errorf("GET %s: Location = %q, want %q", path, got, want)
+		}
+	}
+
+	h, _ = MapHandlerWithOptions(m, notFound, Options{CleanPaths: true, ReservedPaths: []string{"/a"}})
+	if w := get(h, "/x/../a/b"); w.Code != http.StatusNotFound {
+		t.Errorf("cleaned reserved path = %d, want 404", w.Code)
+	}
+
+	expectStatus(t, MapHandler(m, notFound), "/a//b", http.StatusNotFound)
+}
+
+func TestRedirectCookie(t *testing.T) {
+	doc := "- path: /a\n  url: https://a.com\n  utm:\n    utm_campaign: spring\n" +
+		"- path: /b\n  url: https://b.com\n"
+	cookie := &RedirectCookie{
+		Name:     "src",
+		Value:    "{path}:{campaign}",
+		MaxAge:   time.Hour,
+		Domain:   "example.com",
+		SameSite: http.SameSiteLaxMode,
+		Secure:   true,
+	}
+	h, err := YAMLHandlerWithOptions([]byte(doc), notFound, Options{Cookie: cookie})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := serve(h, http.MethodGet, "/a")
+	want := "src=/a:spring; Path=/; Domain=example.com; Max-Age=3600; Secure; SameSite=Lax"
+	if w.Code != http.StatusFound || w.Header().Get("Set-Cookie") != want {
+		t.Errorf("GET /a: Set-Cookie = %q, want %q", w.Header().Get("Set-Cookie"), want)
+	}
+	if c := serve(h, http.MethodGet, "/b").Header().Get("Set-Cookie"); !strings.HasPrefix(c, "src=/b:;") {
+		t.Errorf("GET /b: Set-Cookie = %q, want an empty campaign", c)
+	}
+	if c := serve(h, http.MethodGet, "/x").Header().Get("Set-Cookie"); c != "" {
+		t.Errorf("GET /x: Set-Cookie = %q, want none on a miss", c)
+	}
+	if _, err := MapHandlerWithOptions(nil, notFound, Options{Cookie: &RedirectCookie{}}); err == nil {
+		t.Error("expected an error for a cookie without a name")
+	}
+}
+
+func TestBaseURL(t *testing.T) {
+	doc := "- path: /a\n  url: /landing/promo?x=1\n" +
+		"- path: /b\n  url: https://b.com/x\n" +
+		"- path: /c\n  url: ''\n  targets:\n  - url: rel\n    weight: 1\n"
+	h, err := YAMLHandlerWithOptions([]byte(doc), notFound, Options{
+		BaseURL:         "https://www.example.com/base/",
+		ValidateTargets: RejectInvalidTargets,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectRedirect(t, h, "/a", http.StatusFound, "https://www.example.com/landing/promo?x=1")
+	expectRedirect(t, h, "/b", http.StatusFound, "https://b.com/x")
+	expectRedirect(t, h, "/c", http.StatusFound, "https://www.example.com/base/rel")
+
+	if _, err := MapHandlerWithOptions(nil, notFound, Options{BaseURL: "/rel"}); err == nil {
+		t.Error("expected an error for a relative BaseURL")
+	}
+}
+
+func TestBlockLoops(t *testing.T) {
+	m := map[string]string{
+		"/a":     "/b",
+		"/b":     "https://x.com",
+		"/self":  "http://short.io:8080/a",
+		"/other": "https://other.com/a",
+		"/c":     "/nothing",
+		"/p":     "https://go.to/r/a",
+	}
+	h, err := MapHandlerWithOptions(m, notFound, Options{BlockLoops: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, target := range []string{"http://short.io/a", "http://short.io/self"} {
+		if w := serve(h, http.MethodGet, target); w.Code != http.StatusLoopDetected {
+			t.Errorf("GET %s = %d, want 508", target, w.Code)
+		}
+	}
+	for _, path := range []string{"/b", "/other", "/c", "/p"} {
+		expectStatus(t, h, path, http.StatusFound)
+	}
+
+	h, _ = MapHandlerWithOptions(m, notFound, Options{BlockLoops: true, OwnHosts: []string{"go.to"}, StripPrefix: "/r"})
+	expectStatus(t, h, "/r/p", http.StatusLoopDetected)
+	expectStatus(t, h, "/r/b", http.StatusFound)
+
+	h, _ = MapHandlerWithOptions(m, notFound, Options{})
+	expectStatus(t, h, "/a", http.StatusFound)
+}
+
+func TestHeaders(t *testing.T) {
+	doc := "- path: /a\n  url: https://a.com\n  headers:\n    referrer-policy: no-referrer\n    Link: </x.css>; rel=preload\n" +
+		"- path: /b\n  url: https://b.com\n"
+	h, err := YAMLHandlerWithOptions([]byte(doc), notFound, Options{Headers: map[string]string{"Referrer-Policy": "origin", "X-Short": "1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hd := serve(h, http.MethodGet, "/a").Header()
+	if hd.Get("Referrer-Policy") != "no-referrer" || hd.Get("Link") == "" || hd.Get("X-Short") != "1" || hd.Get("Location") != "https://a.com" {
+		t.Errorf("GET /a headers = %v, want the entry's headers over the defaults", hd)
+	}
+	hd = serve(h, http.MethodGet, "/b").Header()
+	if hd.Get("Referrer-Policy") != "origin" || hd.Get("Link") != "" {
+		t.Errorf("GET /b headers = %v, want only the defaults", hd)
+	}
+	if hd := serve(h, http.MethodGet, "/x").Header(); hd.Get("X-Short") != "" {
+		t.Errorf("GET /x headers = %v, want none on a miss", hd)
+	}
+
+	if _, err := YAMLHandler([]byte("- path: /a\n  url: https://a.com\n  headers:\n    location: https://evil.com\n"), notFound); err == nil {
+		t.Error("expected an error for an entry setting Location")
+	}
+	if _, err := MapHandlerWithOptions(nil, notFound, Options{Headers: map[string]string{"LOCATION": "x"}}); err == nil {
+		t.Error("expected an error for a default Location header")
+	}
+}
@@ -0,0 +1,482 @@
+package urlshort
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// entry is a single redirect as written in a mapping document.
+// Expires, ActiveFrom and ActiveUntil are optional RFC3339
+// timestamps limiting when the entry redirects. An entry sets
+// either URL or Targets, which splits traffic between several
+// urls in proportion to their weights. Paths lists aliases that
+// redirect like Path; see expandAliases. UTM adds query
+// parameters to the target when redirecting. Countries, Devices
+// and Languages override the target for clients in the given
+// countries, for mobile, tablet or desktop clients and for
+// clients preferring the given languages. A Disabled entry
+// answers 410 Gone with DisabledMessage instead of redirecting.
+// Status overrides the redirect status of the handler, and
+// Headers are set on the redirect response.
+type entry struct {
+	Path        string   `yaml:"path" json:"path" toml:"path"`
+	Paths       []string `yaml:"paths,omitempty" json:"paths,omitempty" toml:"paths,omitempty"`
+	URL         string   `yaml:"url" json:"url" toml:"url"`
+	Expires     string   `yaml:"expires,omitempty" json:"expires,omitempty" toml:"expires,omitempty"`
+	ActiveFrom  string   `yaml:"active_from,omitempty" json:"active_from,omitempty" toml:"active_from,omitempty"`
+	ActiveUntil string   `yaml:"active_until,omitempty" json:"active_until,omitempty" toml:"active_until,omitempty"`
+
+	Targets []weightedTarget `yaml:"targets,omitempty" json:"targets,omitempty" toml:"targets,omitempty"`
+
+	UTM map[string]string `yaml:"utm,omitempty" json:"utm,omitempty" toml:"utm,omitempty"`
+
+	Devices   map[string]string `yaml:"devices,omitempty" json:"devices,omitempty" toml:"devices,omitempty"`
+	Languages map[string]string `yaml:"languages,omitempty" json:"languages,omitempty" toml:"languages,omitempty"`
+	Countries map[string]string `yaml:"countries,omitempty" json:"countries,omitempty" toml:"countries,omitempty"`
+
+	Status  int               `yaml:"status,omitempty" json:"status,omitempty" toml:"status,omitempty"`
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty" toml:"headers,omitempty"`
+
+	Disabled        bool   `yaml:"disabled,omitempty" json:"disabled,omitempty" toml:"disabled,omitempty"`
+	DisabledMessage string `yaml:"disabled_message,omitempty" json:"disabled_message,omitempty" toml:"disabled_message,omitempty"`
+}
+
+// weightedTarget is one of the urls of an entry splitting traffic.
+type weightedTarget struct {
+	URL    string `yaml:"url" json:"url" toml:"url"`
+	Weight int    `yaml:"weight" json:"weight" toml:"weight"`
+}
+
+// urls returns every url e may redirect to.
+func (e entry) urls() []string {
+	var urls []string
+	if len(e.Targets) == 0 {
+		urls = append(urls, e.URL)
+	}
+	for _, t := range e.Targets {
+		urls = append(urls, t.URL)
+	}
+	for _, device := range []string{"mobile", "tablet", "desktop"} {
+		if url, ok := e.Devices[device]; ok {
+			urls = append(urls, url)
+		}
+	}
+	for _, tag := range sortedKeys(e.Languages) {
+		urls = append(urls, e.Languages[tag])
+	}
+	for _, country := range sortedKeys(e.Countries) {
+		urls = append(urls, e.Countries[country])
+	}
+	return urls
+}
+
+// resolve returns a copy of e with each of its urls resolved
+// against base, leaving absolute urls unchanged.
+func (e entry) resolve(base *url.URL) (entry, error) {
+	var err error
+	resolveURL := func(target string) string {
+		if err != nil || target == "" {
+			return target
+		}
+		var u *url.URL
+		if u, err = url.Parse(target); err != nil {
+			err = fmt.Errorf("target %s for %s: %s", target, e.Path, err)
+			return target
+		}
+		return base.ResolveReference(u).String()
+	}
+	resolveMap := func(m map[string]string) map[string]string {
+		if m == nil {
+			return nil
+		}
+		resolved := make(map[string]string, len(m))
+		for key, target := range m {
+			resolved[key] = resolveURL(target)
+		}
+		return resolved
+	}
+
+	e.URL = resolveURL(e.URL)
+	if e.Targets != nil {
+		targets := make([]weightedTarget, len(e.Targets))
+		for i, t := range e.Targets {
+			targets[i] = weightedTarget{URL: resolveURL(t.URL), Weight: t.Weight}
+		}
+		e.Targets = targets
+	}
+	e.Devices = resolveMap(e.Devices)
+	e.Languages = resolveMap(e.Languages)
+	e.Countries = resolveMap(e.Countries)
+	return e, err
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// primaryURL returns the url of e, or for entries splitting
+// traffic the url with the largest weight.
+func (e entry) primaryURL() string {
+	if len(e.Targets) == 0 {
+		return e.URL
+	}
+	primary := e.Targets[0]
+	for _, t := range e.Targets[1:] {
+		if t.Weight > primary.Weight {
+			primary = t
+		}
+	}
+	return primary.URL
+}
+
+// ParseYAML accepts either a mapping of paths to urls or a list
+// of path/url entries and returns the resulting redirect map.
+// See YAMLHandler for the format.
+func ParseYAML(yml []byte) (map[string]string, error) {
+	entries, err := decodeYAMLEntries(bytes.NewReader(yml))
+	if err != nil {
+		return nil, err
+	}
+	return buildRedirectMap(entries), nil
+}
+
+// ParseYAMLStrict behaves like ParseYAML but returns a
+// *DuplicatePathError if a path is defined more than once.
+func ParseYAMLStrict(yml []byte) (map[string]string, error) {
+	entries, err := decodeYAMLEntries(bytes.NewReader(yml))
+	if err != nil {
+		return nil, err
+	}
+	return buildRedirectMapStrict(entries)
+}
+
+// ParseJSON accepts either an object of paths to urls or an
+// array of path/url objects and returns the resulting redirect map.
+func ParseJSON(data []byte) (map[string]string, error) {
+	entries, err := decodeJSONEntries(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return buildRedirectMap(entries), nil
+}
+
+// ParseJSONStrict behaves like ParseJSON but returns a
+// *DuplicatePathError if a path is defined more than once.
+func ParseJSONStrict(data []byte) (map[string]string, error) {
+	entries, err := decodeJSONEntries(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return buildRedirectMapStrict(entries)
+}
+
+// ParseTOML reads an array of path/url tables named redirects
+// and returns the resulting redirect map. See TOMLHandler for the
+// format.
+func ParseTOML(data []byte) (map[string]string, error) {
+	entries, err := parseTOMLEntries(data)
+	if err != nil {
+		return nil, err
+	}
+	return buildRedirectMap(entries), nil
+}
+
+// ParseCSV reads csv with a header row naming the path and url
+// columns and returns the resulting redirect map. See CSVHandler
+// for the format.
+func ParseCSV(data []byte) (map[string]string, error) {
+	entries, err := parseCSVEntries(data)
+	if err != nil {
+		return nil, err
+	}
+	return buildRedirectMap(entries), nil
+}
+
+// decodeYAMLEntries decodes the entries of a YAML mapping
+// document from r. An empty document has no entries.
+func decodeYAMLEntries(r io.Reader) ([]entry, error) {
+	return decodeYAMLEntriesStrict(r, false)
+}
+
+// decodeYAMLEntriesStrict behaves like decodeYAMLEntries but, if
+// strict is set, fails on fields entries do not have.
+func decodeYAMLEntriesStrict(r io.Reader, strict bool) ([]entry, error) {
+	var doc redirectDoc
+	d := yaml.NewDecoder(r)
+	d.SetStrict(strict)
+	err := d.Decode(&doc)
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// decodeJSONEntries decodes the entries of a JSON mapping
+// document from r.
+func decodeJSONEntries(r io.Reader) ([]entry, error) {
+	var doc redirectDoc
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// decodeJSONEntriesStrict behaves like decodeJSONEntries but fails
+// on fields entries do not have.
+func decodeJSONEntriesStrict(r io.Reader) ([]entry, error) {
+	var raw json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, err
+	}
+	var doc redirectDoc
+	if err := doc.unmarshalJSON(raw, true); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// parseTOMLEntries reads the redirects array of a TOML document.
+func parseTOMLEntries(data []byte) ([]entry, error) {
+	return parseTOMLEntriesStrict(data, false)
+}
+
+// parseTOMLEntriesStrict behaves like parseTOMLEntries but, if
+// strict is set, fails on keys entries do not have.
+func parseTOMLEntriesStrict(data []byte, strict bool) ([]entry, error) {
+	var tomlPaths struct {
+		Redirects []entry `toml:"redirects"`
+	}
+	md, err := toml.Decode(string(data), &tomlPaths)
+	if err != nil {
+		return nil, err
+	}
+	if undecoded := md.Undecoded(); strict && len(undecoded) > 0 {
+		keys := make([]string, len(undecoded))
+		for i, key := range undecoded {
+			keys[i] = key.String()
+		}
+		return nil, fmt.Errorf("toml has unknown keys: %s", strings.Join(keys, ", "))
+	}
+	return expandAliases(tomlPaths.Redirects)
+}
+
+// parseCSVEntries reads the rows of a CSV document, matching the
+// path, url and optional expires, active_from and active_until
+// columns by their header name.
+func parseCSVEntries(data []byte) ([]entry, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("csv is missing a header row")
+	}
+
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	pathCol, ok := columns["path"]
+	if !ok {
+		return nil, fmt.Errorf("csv header has no path column")
+	}
+	urlCol, ok := columns["url"]
+	if !ok {
+		return nil, fmt.Errorf("csv header has no url column")
+	}
+
+	entries := make([]entry, 0, len(records)-1)
+	for i, record := range records[1:] {
+		if len(record) <= pathCol || len(record) <= urlCol {
+			return nil, fmt.Errorf("csv row %d: missing path or url column", i+2)
+		}
+		field := func(name string) string {
+			col, ok := columns[name]
+			if !ok || len(record) <= col {
+				return ""
+			}
+			return record[col]
+		}
+		entries = append(entries, entry{
+			Path:        record[pathCol],
+			URL:         record[urlCol],
+			Expires:     field("expires"),
+			ActiveFrom:  field("active_from"),
+			ActiveUntil: field("active_until"),
+		})
+	}
+	return entries, nil
+}
+
+// redirectDoc is a mapping document that may be written either
+// as a mapping of paths to urls or as a list of path/url entries.
+// Either way it decodes to a list of entries.
+type redirectDoc []entry
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (d *redirectDoc) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	pathsToUrls := map[string]string{}
+	if err := unmarshal(&pathsToUrls); err == nil {
+		*d = mapEntries(pathsToUrls)
+		return nil
+	}
+	ymlPaths := []entry{}
+	if err := unmarshal(&ymlPaths); err != nil {
+		return fmt.Errorf("yaml is neither a path to url mapping nor a list of path/url entries: %s", err)
+	}
+	entries, err := expandAliases(ymlPaths)
+	if err != nil {
+		return err
+	}
+	*d = entries
+	return nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *redirectDoc) UnmarshalJSON(data []byte) error {
+	return d.unmarshalJSON(data, false)
+}
+
+// unmarshalJSON decodes data into d. If strict is set, entries
+// with fields they do not have are an error.
+func (d *redirectDoc) unmarshalJSON(data []byte, strict bool) error {
+	pathsToUrls := map[string]string{}
+	if err := json.Unmarshal(data, &pathsToUrls); err == nil {
+		*d = mapEntries(pathsToUrls)
+		return nil
+	}
+	jsonPaths := []entry{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if strict {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(&jsonPaths); err != nil {
+		return fmt.Errorf("json is neither a path to url object nor an array of path/url objects: %s", err)
+	}
+	entries, err := expandAliases(jsonPaths)
+	if err != nil {
+		return err
+	}
+	*d = entries
+	return nil
+}
+
+// expandAliases replaces every entry listing several paths with
+// one entry per path, all sharing the same target. An error is
+// returned if a path belongs to more than one such alias group.
+func expandAliases(entries []entry) ([]entry, error) {
+	expanded := make([]entry, 0, len(entries))
+	groups := make(map[string]int)
+	for i, e := range entries {
+		if len(e.Paths) == 0 {
+			expanded = append(expanded, e)
+			continue
+		}
+		paths := e.Paths
+		if e.Path != "" {
+			paths = append([]string{e.Path}, paths...)
+		}
+		for _, path := range paths {
+			if j, ok := groups[path]; ok && j != i {
+				return nil, fmt.Errorf("path %s is an alias in more than one entry", path)
+			}
+			groups[path] = i
+			alias := e
+			alias.Path, alias.Paths = path, nil
+			expanded = append(expanded, alias)
+		}
+	}
+	return expanded, nil
+}
+
+// mapEntries converts a mapping of paths to urls into a list of
+// entries sorted by path.
+func mapEntries(pathsToUrls map[string]string) []entry {
+	paths := make([]string, 0, len(pathsToUrls))
+	for path := range pathsToUrls {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	entries := make([]entry, 0, len(paths))
+	for _, path := range paths {
+		entries = append(entries, entry{Path: path, URL: pathsToUrls[path]})
+	}
+	return entries
+}
+
+// buildRedirectMap flattens entries into a mapping of paths to
+// urls. Entries splitting traffic map to their primary url.
+func buildRedirectMap(data []entry) map[string]string {
+	redirects := make(map[string]string)
+	for _, e := range data {
+		redirects[e.Path] = e.primaryURL()
+	}
+	return redirects
+}
+
+// DuplicatePathError is returned by the strict parsers when the
+// same path is defined by more than one entry.
+type DuplicatePathError struct {
+	Collisions []PathCollision
+}
+
+// PathCollision lists every target a duplicated path was given,
+// in the order the entries appeared.
+type PathCollision struct {
+	Path string
+	URLs []string
+}
+
+func (e *DuplicatePathError) Error() string {
+	msgs := make([]string, 0, len(e.Collisions))
+	for _, c := range e.Collisions {
+		msgs = append(msgs, fmt.Sprintf("%s defined %d times (%s)", c.Path, len(c.URLs), strings.Join(c.URLs, ", ")))
+	}
+	return "duplicate paths: " + strings.Join(msgs, "; ")
+}
+
+// buildRedirectMapStrict behaves like buildRedirectMap but
+// returns a *DuplicatePathError instead of letting later entries
+// silently replace earlier ones.
+func buildRedirectMapStrict(data []entry) (map[string]string, error) {
+	redirects := make(map[string]string)
+	targets := make(map[string][]string)
+	var duplicated []string
+	for _, e := range data {
+		targets[e.Path] = append(targets[e.Path], e.primaryURL())
+		if len(targets[e.Path]) == 2 {
+			duplicated = append(duplicated, e.Path)
+		}
+		redirects[e.Path] = e.primaryURL()
+	}
+	if len(duplicated) == 0 {
+		return redirects, nil
+	}
+
+	err := &DuplicatePathError{}
+	for _, path := range duplicated {
+		err.Collisions = append(err.Collisions, PathCollision{Path: path, URLs: targets[path]})
+	}
+	return nil, err
+}
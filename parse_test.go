@@ -0,0 +1,103 @@
+package urlshort
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestParseYAMLStrict(t *testing.T) {
+	doc := "- path: /a\n  url: https://1.com\n- path: /b\n  url: https://b.com\n- path: /a\n  url: https://2.com\n"
+	_, err := ParseYAMLStrict([]byte(doc))
+	dup, ok := err.(*DuplicatePathError)
+	if !ok {
+		t.Fatalf("ParseYAMLStrict error = %v, want a *DuplicatePathError", err)
+	}
+	if len(dup.Collisions) != 1 || dup.Collisions[0].URLs[1] != "https://2.com" {
+		t.Errorf("collisions = %+v, want one for /a", dup.Collisions)
+	}
+
+	m, err := ParseYAML([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m["/a"] != "https://2.com" {
+		t.Errorf("ParseYAML kept %q for /a, want the last entry", m["/a"])
+	}
+}
+
+func TestParseJSONStrict(t *testing.T) {
+	if _, err := ParseJSONStrict([]byte(`[{"path": "/a", "url": "x"}, {"path": "/b", "url": "y"}]`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseJSONStrict([]byte(`[{"path": "/a", "url": "x"}, {"path": "/a", "url": "y"}]`)); err == nil {
+		t.Error("expected a duplicate path error")
+	}
+}
+
+func TestAliases(t *testing.T) {
+	m, err := ParseYAML([]byte("- paths: [/docs, /help]\n  url: https://d.com\n- path: /x\n  url: https://x.com\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m) != 3 || m["/docs"] != "https://d.com" || m["/help"] != "https://d.com" {
+		t.Errorf("ParseYAML = %v, want /docs and /help to share a target", m)
+	}
+
+	m, err = ParseJSON([]byte(`[{"path": "/a", "paths": ["/b"], "url": "https://a.com"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m["/a"] != "https://a.com" || m["/b"] != "https://a.com" {
+		t.Errorf("ParseJSON = %v, want path and paths combined", m)
+	}
+
+	if _, err := ParseYAML([]byte("- paths: [/docs, /help]\n  url: https://d.com\n- paths: [/help]\n  url: https://h.com\n")); err == nil {
+		t.Error("expected an error for an alias claimed by two entries")
+	}
+	if _, err := ParseJSON([]byte(`[{"paths": ["/a", "/a"], "url": "https://a.com"}]`)); err != nil {
+		t.Errorf("repeated alias within one entry: %v", err)
+	}
+}
+
+func TestStrictFields(t *testing.T) {
+	yml := "- path: /a\n  urls: https://a.com\n- path: /b\n  url: https://b.com\n"
+	js := `[{"path": "/a", "urls": "https://a.com"}, {"path": "/b", "url": "https://b.com", "targets": null}]`
+	nested := `[{"path": "/a", "targets": [{"url": "https://a.com", "weigth": 1}]}]`
+	tml := "[[redirects]]\npath = \"/a\"\nurls = \"https://a.com\"\n"
+	strict := Options{StrictFields: true}
+
+	if _, err := YAMLHandlerWithOptions([]byte(yml), notFound, strict); err == nil || !strings.Contains(err.Error(), "urls") {
+		t.Errorf("YAML error = %v, want one naming urls", err)
+	}
+	if _, err := JSONHandlerWithOptions([]byte(js), notFound, strict); err == nil || !strings.Contains(err.Error(), "urls") {
+		t.Errorf("JSON error = %v, want one naming urls", err)
+	}
+	if _, err := JSONHandlerWithOptions([]byte(nested), notFound, strict); err == nil {
+		t.Error("expected an error for an unknown target field")
+	}
+	if _, err := TOMLHandlerWithOptions([]byte(tml), notFound, strict); err == nil || !strings.Contains(err.Error(), "urls") {
+		t.Errorf("TOML error = %v, want one naming urls", err)
+	}
+
+	h, err := YAMLHandlerWithOptions([]byte(yml), notFound, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, h, "/b", http.StatusFound)
+	if _, err := JSONHandlerWithOptions([]byte(js), notFound, Options{}); err != nil {
+		t.Errorf("lenient JSON: %v", err)
+	}
+	if _, err := TOMLHandlerWithOptions([]byte(tml), notFound, Options{}); err != nil {
+		t.Errorf("lenient TOML: %v", err)
+	}
+
+	for _, doc := range []string{"- path: /a\n  url: https://a.com\n", "/a: https://a.com\n"} {
+		if _, err := YAMLHandlerWithOptions([]byte(doc), notFound, strict); err != nil {
+			t.Errorf("strict %q: %v", doc, err)
+		}
+	}
+	if _, err := JSONHandlerWithOptions([]byte(`{"/a": "https://a.com"}`), notFound, strict); err != nil {
+		t.Errorf("strict JSON map: %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package urlshort
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// PrefixStore is a Store that supports prefix redirects in
+// addition to exact ones. A path ending in /** matches any
+// request path below it, at any depth, and the remainder of the
+// request path is appended to the target URL. Exact paths take
+// precedence over prefixes, and the longest matching prefix wins.
+//
+// A path ending in /* is a prefix too, for compatibility. Prefer
+// /**, which means the same with Options.Globs, where a * only
+// matches a single path segment.
+type PrefixStore struct {
+	exact    map[string]string
+	prefixes []prefixRule
+}
+
+type prefixRule struct {
+	prefix string
+	url    string
+}
+
+// NewPrefixStore builds a PrefixStore from a mapping of paths to
+// urls, where paths ending in /** or /* are treated as prefixes.
+func NewPrefixStore(pathsToUrls map[string]string) *PrefixStore {
+	s := &PrefixStore{exact: make(map[string]string)}
+	for path, url := range pathsToUrls {
+		if strings.HasSuffix(path, "/**") {
+			s.prefixes = append(s.prefixes, prefixRule{prefix: strings.TrimSuffix(path, "**"), url: url})
+		} else if strings.HasSuffix(path, "/*") {
+			s.prefixes = append(s.prefixes, prefixRule{prefix: strings.TrimSuffix(path, "*"), url: url})
+		} else {
+			s.exact[path] = url
+		}
+	}
+	sort.Slice(s.prefixes, func(i, j int) bool {
+		return len(s.prefixes[i].prefix) > len(s.prefixes[j].prefix)
+	})
+	return s
+}
+
+// Lookup returns the exact match for path if there is one, and
+// otherwise the target of the longest prefix matching path with
+// the rest of the path appended.
+func (s *PrefixStore) Lookup(path string) (string, bool, error) {
+	if url, ok := s.exact[path]; ok {
+		return url, true, nil
+	}
+	for _, rule := range s.prefixes {
+		if strings.HasPrefix(path, rule.prefix) {
+			return joinRemainder(rule.url, strings.TrimPrefix(path, rule.prefix)), true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// joinRemainder appends the unmatched part of a request path to
+// target with exactly one slash between them.
+func joinRemainder(target, remainder string) string {
+	if remainder == "" {
+		return target
+	}
+	return strings.TrimSuffix(target, "/") + "/" + remainder
+}
+
+// PrefixHandler behaves like MapHandler but also accepts prefix entries such as /gh/**,
+// which redirect /gh/repo/issues to the prefix target with repo/issues appended. See
+// PrefixStore.
+func PrefixHandler(pathsToUrls map[string]string, fallback http.Handler) http.HandlerFunc {
+	return StoreHandler(NewPrefixStore(pathsToUrls), fallback)
+}
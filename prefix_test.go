@@ -0,0 +1,35 @@
+package urlshort
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestPrefixHandler(t *testing.T) {
+	h := PrefixHandler(map[string]string{
+		"/gh/**":    "https://github.com/myorg/",
+		"/gh/x/**":  "https://x.com",
+		"/gh/exact": "https://e.com",
+	}, notFound)
+	for path, want := range map[string]string{
+		"/gh/repo/issues": "https://github.com/myorg/repo/issues",
+		"/gh/x/a/b":       "https://x.com/a/b",
+		"/gh/exact":       "https://e.com",
+		"/gh/":            "https://github.com/myorg/",
+	} {
+		expectRedirect(t, h, path, http.StatusFound, want)
+	}
+	expectStatus(t, h, "/gh", http.StatusNotFound)
+}
+
+func TestPrefixStoreSingleStar(t *testing.T) {
+	s := NewPrefixStore(map[string]string{"/gh/**": "https://github.com", "/old/*": "https://old.com"})
+	for path, want := range map[string]string{
+		"/gh/repo/issues": "https://github.com/repo/issues",
+		"/old/a/b":        "https://old.com/a/b",
+	} {
+		if u, ok, _ := s.Lookup(path); !ok || u != want {
+			t.Errorf("Lookup(%s) = %q, %v, want %q", path, u, ok, want)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package urlshort
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+)
+
+// PreviewHandler will return an http.HandlerFunc that answers
+// GET /preview?path=/some-path with the URL the path redirects
+// to, as a {"path": ..., "url": ...} JSON object, instead of
+// redirecting. The path may carry a query string. A path that
+// does not resolve, or whose entry is not active, is answered
+// with 404, and a disabled one with 410. Single-use paths are
+// answered with 404 too, since showing their target would give
+// it away without using it up; see SingleUseStore.
+func PreviewHandler(store Store) http.HandlerFunc {
+	handler, _ := PreviewHandlerWithOptions(store, Options{})
+	return handler
+}
+
+// PreviewHandlerWithOptions behaves like PreviewHandler but
+// resolves paths as configured by opts, so that previews match a
+// handler built with the same Store and Options: the path is
+// stripped, cleaned and checked against the reserved paths as a
+// request for it would be, and the UTM parameters, query and
+// fragment are added to the target. An error is returned if opts
+// is invalid.
+func PreviewHandlerWithOptions(store Store, opts Options) (http.HandlerFunc, error) {
+	opts, err := opts.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/preview" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		path := r.URL.Query().Get("path")
+		if path == "" {
+			http.Error(w, "path is required", http.StatusBadRequest)
+			return
+		}
+		// Resolve the path as a request for it would be, so a
+		// query string it carries is kept by PreserveQuery.
+		u, err := url.Parse(path)
+		if err != nil {
+			http.Error(w, "invalid path: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		pr := r.Clone(r.Context())
+		pr.URL = u
+		resolved, ok := opts.requestPath(pr)
+		if !ok {
+			http.Error(w, "no redirect for "+u.Path, http.StatusNotFound)
+			return
+		}
+		target, _, ok, err := opts.lookup(pr, store, resolved, false)
+		var disabled *DisabledError
+		if errors.As(err, &disabled) {
+			gone(w, disabled)
+			return
+		}
+		if err != nil && !errors.Is(err, errInactive) {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err != nil || !ok || !opts.allowed(target) {
+			http.Error(w, "no redirect for "+u.Path, http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, entry{Path: u.Path, URL: opts.target(pr, target)})
+	}, nil
+}
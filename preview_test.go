@@ -0,0 +1,106 @@
+package urlshort
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestPreviewHandler(t *testing.T) {
+	h, err := PreviewHandlerWithOptions(MapStore{"/a/": "https://a.com"}, Options{TrailingSlash: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := serve(h, http.MethodGet, "/preview?path=/a")
+	if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != `{"path":"/a","url":"https://a.com"}` {
+		t.Errorf("preview of /a = %d %q", w.Code, w.Body)
+	}
+	w = serve(h, http.MethodGet, "/preview?path=/b")
+	if w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), "no redirect for /b") {
+		t.Errorf("preview of /b = %d %q", w.Code, w.Body)
+	}
+}
+
+func TestPreviewTarget(t *testing.T) {
+	opts := Options{UTM: map[string]string{"utm_source": "s"}, PreserveQuery: true, Fragment: "top"}
+	store := MapStore{"/a": "https://a.com/x"}
+	pv, err := PreviewHandlerWithOptions(store, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := StoreHandlerWithOptions(store, notFound, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := serve(pv, http.MethodGet, "/preview?path="+url.QueryEscape("/a?ref=1"))
+	var got entry
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode %q: %v", w.Body, err)
+	}
+	location := serve(h, http.MethodGet, "/a?ref=1").Header().Get("Location")
+	if w.Code != http.StatusOK || got.Path != "/a" || got.URL != location {
+		t.Errorf("preview = %d %+v, want the redirect's target %q", w.Code, got, location)
+	}
+}
+
+func TestPreviewPaths(t *testing.T) {
+	opts := Options{StripPrefix: "/r/", CleanPaths: true, ReservedPaths: []string{"/admin"}}
+	store := MapStore{"/promo": "https://p.com", "/admin": "https://evil.com"}
+	pv, err := PreviewHandlerWithOptions(store, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := StoreHandlerWithOptions(store, notFound, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"/r/promo", "/r//x/../promo", "/promo", "/r/admin"} {
+		want := serve(h, http.MethodGet, path).Code
+		if want == http.StatusFound {
+			want = http.StatusOK
+		}
+		if got := serve(pv, http.MethodGet, "/preview?path="+url.QueryEscape(path)).Code; got != want {
+			t.Errorf("preview of %s = %d, want %d like the handler", path, got, want)
+		}
+	}
+}
+
+func TestPreviewDoesNotConsume(t *testing.T) {
+	s := openTestBolt(t, BoltOptions{CountHits: true})
+	s.PutSingleUse("/once", "https://o.com")
+	s.Put("/a", "https://a.com")
+	pv := PreviewHandler(s)
+	if w := serve(pv, http.MethodGet, "/preview?path=/once"); w.Code != http.StatusNotFound || strings.Contains(w.Body.String(), "o.com") {
+		t.Errorf("preview of a single-use link = %d %q, want 404 without the target", w.Code, w.Body)
+	}
+	expectStatus(t, pv, "/preview?path=/a", http.StatusOK)
+
+	h := StoreHandler(s, notFound)
+	expectStatus(t, h, "/once", http.StatusFound)
+	if n, _ := s.Hits("/a"); n != 0 {
+		t.Errorf("preview counted %d hits", n)
+	}
+}
+
+func TestPreviewInactive(t *testing.T) {
+	doc := "- path: /old\n  url: https://o.com\n  expires: 2000-01-01T00:00:00Z\n" +
+		"- path: /d\n  url: https://d.com\n  disabled: true\n  disabled_message: gone for good\n"
+	entries, err := decodeYAMLEntries(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := Options{}.entryStore(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pv := PreviewHandler(s)
+	expectStatus(t, pv, "/preview?path=/old", http.StatusNotFound)
+	w := serve(pv, http.MethodGet, "/preview?path=/d")
+	if w.Code != http.StatusGone || !strings.Contains(w.Body.String(), "gone for good") {
+		t.Errorf("preview of a disabled link = %d %q", w.Code, w.Body)
+	}
+	expectStatus(t, PreviewHandler(errStore{}), "/preview?path=/a", http.StatusInternalServerError)
+}
@@ -0,0 +1,86 @@
+package urlshort
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+const (
+	// DefaultQRSize is the width and height in pixels of QR codes
+	// requested without a size.
+	DefaultQRSize = 256
+	// MaxQRSize is the largest size a QR code may be requested at.
+	MaxQRSize = 1024
+	// minQRSize is the smallest size a QR code can be read at.
+	minQRSize = 64
+)
+
+// QRHandler will return an http.HandlerFunc that answers
+// GET /qr?path=/some-path with a PNG QR code encoding baseURL
+// followed by the path. The optional size parameter sets the
+// width and height of the image in pixels, between 64 and
+// MaxQRSize, and defaults to DefaultQRSize.
+func QRHandler(baseURL string) http.HandlerFunc {
+	return qrHandler(baseURL, nil)
+}
+
+// QRHandlerWithStore behaves like QRHandler but only generates QR
+// codes for paths found in store, answering 404 for others,
+// including single-use paths; see SingleUseStore.
+func QRHandlerWithStore(baseURL string, store Store) http.HandlerFunc {
+	return qrHandler(baseURL, store)
+}
+
+func qrHandler(baseURL string, store Store) http.HandlerFunc {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/qr" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		query := r.URL.Query()
+		path := query.Get("path")
+		if !strings.HasPrefix(path, "/") {
+			http.Error(w, "path must start with /", http.StatusBadRequest)
+			return
+		}
+		size := DefaultQRSize
+		if s := query.Get("size"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < minQRSize || n > MaxQRSize {
+				http.Error(w, "size must be a number between "+strconv.Itoa(minQRSize)+" and "+strconv.Itoa(MaxQRSize), http.StatusBadRequest)
+				return
+			}
+			size = n
+		}
+		if store != nil {
+			_, ok, err := Reveal(store, path)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if !ok {
+				http.Error(w, "no redirect for "+path, http.StatusNotFound)
+				return
+			}
+		}
+
+		png, err := qrcode.Encode(baseURL+path, qrcode.Medium, size)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("Content-Length", strconv.Itoa(len(png)))
+		w.Write(png)
+	}
+}
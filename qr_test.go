@@ -0,0 +1,32 @@
+package urlshort
+
+import (
+	"bytes"
+	"image/png"
+	"net/http"
+	"testing"
+)
+
+func TestQRHandler(t *testing.T) {
+	h := QRHandlerWithStore("https://s.io/", MapStore{"/p": "https://x.com"})
+	w := serve(h, http.MethodGet, "/qr?path=/p&size=128")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("GET /qr = %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	img, err := png.Decode(bytes.NewReader(w.Body.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 128 || b.Dy() != 128 {
+		t.Errorf("image is %dx%d, want 128x128", b.Dx(), b.Dy())
+	}
+	expectStatus(t, h, "/qr?path=/q", http.StatusNotFound)
+	expectStatus(t, h, "/qr?path=/p&size=99999", http.StatusBadRequest)
+
+	expectStatus(t, QRHandler("https://s.io"), "/qr?path=/q", http.StatusOK)
+
+	s := openTestBolt(t, BoltOptions{})
+	s.PutSingleUse("/once", "https://o.com")
+	expectStatus(t, QRHandlerWithStore("https://s.io", s), "/qr?path=/once", http.StatusNotFound)
+	expectStatus(t, StoreHandler(s, notFound), "/once", http.StatusFound)
+}
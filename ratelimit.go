@@ -0,0 +1,114 @@
+package urlshort
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// defaultRateLimitIdle is how long the limiter of a client is
+// kept after its last request by default.
+const defaultRateLimitIdle = 3 * time.Minute
+
+// RateLimitOptions configures RateLimitHandlerWithOptions.
+type RateLimitOptions struct {
+	// Limit is the sustained number of requests per second each
+	// client IP may make.
+	Limit rate.Limit
+
+	// Burst is the number of requests a client may make at once.
+	Burst int
+
+	// TrustForwardedFor identifies clients by the X-Forwarded-For
+	// header instead of the connection. See Options for when this
+	// is safe.
+	TrustForwardedFor bool
+
+	// Idle is how long the limiter of a client that stopped making
+	// requests is kept before it is dropped to bound memory use.
+	// Zero means three minutes.
+	Idle time.Duration
+
+	// Clock tells the time requests are made at. Nil means
+	// SystemClock.
+	Clock Clock
+}
+
+// RateLimitHandler will return an http.HandlerFunc that serves
+// next but answers with http.StatusTooManyRequests once a client
+// IP makes more than perIP requests per second, allowing bursts
+// of up to burst requests.
+func RateLimitHandler(next http.Handler, perIP rate.Limit, burst int) http.HandlerFunc {
+	return RateLimitHandlerWithOptions(next, RateLimitOptions{Limit: perIP, Burst: burst})
+}
+
+// RateLimitHandlerWithOptions behaves like RateLimitHandler but
+// limits clients as configured by opts.
+func RateLimitHandlerWithOptions(next http.Handler, opts RateLimitOptions) http.HandlerFunc {
+	if opts.Idle <= 0 {
+		opts.Idle = defaultRateLimitIdle
+	}
+	if opts.Clock == nil {
+		opts.Clock = SystemClock
+	}
+	limiters := &clientLimiters{opts: opts, clients: make(map[string]*clientLimiter), now: opts.Clock.Now}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.RemoteAddr
+		if ip := clientIP(r, opts.TrustForwardedFor); ip != nil {
+			key = ip.String()
+		}
+		if !limiters.allow(key) {
+			if opts.Limit > 0 {
+				retry := math.Ceil(1 / float64(opts.Limit))
+				w.Header().Set("Retry-After", strconv.Itoa(int(retry)))
+			}
+			http.Error(w, "too many requests", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+// clientLimiters holds a rate limiter per client, dropping the
+// ones that have been idle for too long.
+type clientLimiters struct {
+	opts RateLimitOptions
+	now  func() time.Time
+
+	mu        sync.Mutex
+	clients   map[string]*clientLimiter
+	lastSweep time.Time
+}
+
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// allow reports whether the client identified by key may make a
+// request now.
+func (l *clientLimiters) allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if now.Sub(l.lastSweep) >= l.opts.Idle {
+		for k, c := range l.clients {
+			if now.Sub(c.lastSeen) >= l.opts.Idle {
+				delete(l.clients, k)
+			}
+		}
+		l.lastSweep = now
+	}
+	c, ok := l.clients[key]
+	if !ok {
+		c = &clientLimiter{limiter: rate.NewLimiter(l.opts.Limit, l.opts.Burst)}
+		l.clients[key] = c
+	}
+	c.lastSeen = now
+	return c.limiter.AllowN(now, 1)
+}
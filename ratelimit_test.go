@@ -0,0 +1,43 @@
+package urlshort
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimitHandler(t *testing.T) {
+	next := MapHandler(map[string]string{"/a": "https://a.com"}, notFound)
+	h := RateLimitHandlerWithOptions(next, RateLimitOptions{Limit: 1, Burst: 2, TrustForwardedFor: true})
+	from := func(ip string) int {
+		r := httptest.NewRequest(http.MethodGet, "/a", nil)
+		r.Header.Set("X-Forwarded-For", ip)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+	for i, want := range []int{http.StatusFound, http.StatusFound, http.StatusTooManyRequests} {
+		if got := from("1.1.1.1"); got != want {
+			t.Errorf("request %d = %d, want %d", i, got, want)
+		}
+	}
+	if got := from("2.2.2.2"); got != http.StatusFound {
+		t.Errorf("another client = %d, want 302", got)
+	}
+}
+
+func TestRateLimitIdleClients(t *testing.T) {
+	l := &clientLimiters{
+		opts:    RateLimitOptions{Limit: 1, Burst: 1, Idle: time.Minute},
+		clients: map[string]*clientLimiter{},
+	}
+	now := time.Unix(1000, 0)
+	l.now = func() time.Time { return now }
+	l.allow("a")
+	now = now.Add(2 * time.Minute)
+	l.allow("b")
+	if n := len(l.clients); n != 1 {
+		t.Errorf("tracking %d clients, want the idle one dropped", n)
+	}
+}
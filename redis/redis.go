@@ -0,0 +1,110 @@
+// Package redis serves redirects stored as string keys in Redis.
+package redis
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/bcpoole/urlshort"
+	"github.com/redis/go-redis/v9"
+)
+
+// Store is a urlshort.Store backed by string keys in Redis. Every
+// lookup queries Redis, so writes made by any instance sharing
+// the server are visible immediately.
+type Store struct {
+	client    *redis.Client
+	keyPrefix string
+}
+
+// NewStore returns a Store that resolves a path by reading the key
+// keyPrefix+path.
+func NewStore(client *redis.Client, keyPrefix string) *Store {
+	return &Store{client: client, keyPrefix: keyPrefix}
+}
+
+// Lookup returns the url stored under the key for path.
+func (s *Store) Lookup(path string) (string, bool, error) {
+	return s.LookupContext(context.Background(), path)
+}
+
+// LookupContext returns the url stored under the key for path,
+// giving up when ctx is done.
+func (s *Store) LookupContext(ctx context.Context, path string) (string, bool, error) {
+	url, err := s.client.Get(ctx, s.keyPrefix+path).Result()
+	if err == redis.Nil {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return url, true, nil
+}
+
+// Put stores a redirect from path to url under the key for path,
+// replacing any existing one. url must be an absolute URL.
+func (s *Store) Put(path, url string) error {
+	if err := urlshort.CheckTarget(url); err != nil {
+		return err
+	}
+	return s.client.Set(context.Background(), s.keyPrefix+path, url, 0).Err()
+}
+
+// Insert stores a redirect from path to url with SETNX, unless
+// the key for path already exists, and reports whether it did.
+// url must be an absolute URL.
+func (s *Store) Insert(path, url string) (bool, error) {
+	if err := urlshort.CheckTarget(url); err != nil {
+		return false, err
+	}
+	return s.client.SetNX(context.Background(), s.keyPrefix+path, url, 0).Result()
+}
+
+// Delete removes the redirect for path. Deleting a path that has
+// no mapping is not an error.
+func (s *Store) Delete(path string) error {
+	return s.client.Del(context.Background(), s.keyPrefix+path).Err()
+}
+
+// Each calls fn for every key starting with the key prefix of the
+// store, in no particular order, stopping at the first error fn
+// returns. Keys are read with SCAN, so keys written or removed
+// meanwhile may or may not be seen.
+func (s *Store) Each(fn func(path, url string) error) error {
+	ctx := context.Background()
+	iter := s.client.Scan(ctx, 0, globEscaper.Replace(s.keyPrefix)+"*", 0).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		url, err := s.client.Get(ctx, key).Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(strings.TrimPrefix(key, s.keyPrefix), url); err != nil {
+			return err
+		}
+	}
+	return iter.Err()
+}
+
+// globEscaper escapes the characters special to the patterns of
+// SCAN MATCH.
+var globEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`)
+
+// Ping checks that the Redis server can be reached.
+func (s *Store) Ping(ctx context.Context) error {
+	return s.client.Ping(ctx).Err()
+}
+
+// Handler looks up each request path in Redis under keyPrefix and redirects to the stored
+// url. Else falls back to provided Handler, including when Redis cannot be reached.
+func Handler(client *redis.Client, keyPrefix string, fallback http.Handler) (http.HandlerFunc, error) {
+	if client == nil {
+		return nil, errors.New("redis client is nil")
+	}
+	return urlshort.StoreHandler(NewStore(client, keyPrefix), fallback), nil
+}
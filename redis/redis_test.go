@@ -0,0 +1,104 @@
+package redis
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/bcpoole/urlshort"
+	"github.com/redis/go-redis/v9"
+)
+
+// get sends a GET for target to h and returns the recorded
+// response.
+func get(h http.Handler, target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+	return w
+}
+
+func TestHandler(t *testing.T) {
+	mr := miniredis.RunT(t)
+	mr.Set("u:/r", "https://r.com")
+	h, err := Handler(redis.NewClient(&redis.Options{Addr: mr.Addr()}), "u:", http.NotFoundHandler())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := get(h, "/r"); w.Code != http.StatusFound || w.Header().Get("Location") != "https://r.com" {
+		t.Errorf("GET /r = %d %q, want 302 https://r.com", w.Code, w.Header().Get("Location"))
+	}
+	if w := get(h, "/x"); w.Code != http.StatusNotFound {
+		t.Errorf("GET /x = %d, want 404", w.Code)
+	}
+
+	mr.Close()
+	if w := get(h, "/r"); w.Code != http.StatusNotFound {
+		t.Errorf("GET /r with Redis down = %d, want 404", w.Code)
+	}
+
+	if _, err := Handler(nil, "", http.NotFoundHandler()); err == nil {
+		t.Error("expected an error for a nil client")
+	}
+}
+
+func TestStoreWrites(t *testing.T) {
+	mr := miniredis.RunT(t)
+	mr.Set("other", "https://no.com")
+	mr.Set("ux:/x", "https://no.com")
+	s := NewStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}), "u*:")
+
+	if err := s.Put("/a", "https://a.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put("/c", "relative"); err == nil {
+		t.Error("expected an error for a relative URL")
+	}
+	if ok, err := s.Insert("/b", "https://b.com"); !ok || err != nil {
+		t.Fatalf("Insert(/b) = %v, %v, want true", ok, err)
+	}
+	if ok, err := s.Insert("/b", "https://other.com"); ok || err != nil {
+		t.Fatalf("second Insert(/b) = %v, %v, want false", ok, err)
+	}
+	if u, ok, _ := s.Lookup("/a"); !ok || u != "https://a.com" {
+		t.Errorf("Lookup(/a) = %q, %v", u, ok)
+	}
+
+	if err := s.Delete("/b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete("/nope"); err != nil {
+		t.Errorf("deleting a missing key: %v", err)
+	}
+
+	// Each lists only the keys under the prefix, whose * is
+	// matched literally.
+	got := map[string]string{}
+	if err := s.Each(func(path, url string) error {
+		got[path] = url
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got["/a"] != "https://a.com" {
+		t.Errorf("Each listed %v, want only /a", got)
+	}
+
+	var _ urlshort.InsertStore = s
+	var _ urlshort.ListableStore = s
+}
+
+func TestMigrate(t *testing.T) {
+	mr := miniredis.RunT(t)
+	s := NewStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}), "u:")
+	n, err := urlshort.Migrate(urlshort.MapStore{"/a": "https://a.com", "/b": "https://b.com"}, s)
+	if err != nil || n != 2 {
+		t.Fatalf("Migrate = %d, %v, want 2", n, err)
+	}
+	if u, ok, _ := s.Lookup("/a"); !ok || u != "https://a.com" {
+		t.Errorf("Lookup(/a) = %q, %v", u, ok)
+	}
+	if _, err := urlshort.Migrate(s, struct{ urlshort.Store }{urlshort.MapStore{}}); err == nil {
+		t.Error("expected an error migrating into a store without Put")
+	}
+}
@@ -0,0 +1,66 @@
+package urlshort
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+)
+
+// RegexRule redirects request paths matching Pattern to
+// Template. The pattern must match the whole path. Capture
+// groups are substituted into the template using $1, $2, ... or
+// ${name} for named groups, as in regexp.Regexp.Expand.
+type RegexRule struct {
+	Pattern  string
+	Template string
+}
+
+// RegexStore is a Store that resolves paths against an ordered
+// list of regular expressions. The first matching rule wins.
+type RegexStore struct {
+	rules []compiledRegexRule
+}
+
+type compiledRegexRule struct {
+	re       *regexp.Regexp
+	template string
+}
+
+// NewRegexStore compiles rules in order. An error is returned
+// if any pattern is not a valid regular expression.
+func NewRegexStore(rules []RegexRule) (*RegexStore, error) {
+	s := &RegexStore{rules: make([]compiledRegexRule, 0, len(rules))}
+	for _, rule := range rules {
+		re, err := regexp.Compile(`^(?:` + rule.Pattern + `)$`)
+		if err != nil {
+			return nil, fmt.Errorf("compile %q: %s", rule.Pattern, err)
+		}
+		s.rules = append(s.rules, compiledRegexRule{re: re, template: rule.Template})
+	}
+	return s, nil
+}
+
+// Lookup returns the expanded template of the first rule whose
+// pattern matches path.
+func (s *RegexStore) Lookup(path string) (string, bool, error) {
+	for _, rule := range s.rules {
+		match := rule.re.FindStringSubmatchIndex(path)
+		if match == nil {
+			continue
+		}
+		return string(rule.re.ExpandString(nil, rule.template, path, match)), true, nil
+	}
+	return "", false, nil
+}
+
+// RegexHandler tries each rule in order and redirects to the template of the first one
+// matching the request path. Else falls back to provided Handler, which may itself be
+// an exact-match handler such as MapHandler. Invalid patterns are reported here rather
+// than when serving requests.
+func RegexHandler(rules []RegexRule, fallback http.Handler) (http.HandlerFunc, error) {
+	store, err := NewRegexStore(rules)
+	if err != nil {
+		return nil, err
+	}
+	return StoreHandler(store, fallback), nil
+}
@@ -0,0 +1,29 @@
+package urlshort
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRegexHandler(t *testing.T) {
+	rules := []RegexRule{
+		{`/issue/(\d+)`, "https://t.com/t/$1"},
+		{`/u/(?P<user>\w+)`, "https://u.com/${user}"},
+	}
+	h, err := RegexHandler(rules, MapHandler(map[string]string{"/issue/x": "https://x.com"}, notFound))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]string{
+		"/issue/42": "https://t.com/t/42",
+		"/u/bob":    "https://u.com/bob",
+		"/issue/x":  "https://x.com",
+	} {
+		expectRedirect(t, h, path, http.StatusFound, want)
+	}
+	expectStatus(t, h, "/issue/42/x", http.StatusNotFound)
+
+	if _, err := RegexHandler([]RegexRule{{"(", ""}}, notFound); err == nil {
+		t.Error("expected an error for an invalid pattern")
+	}
+}
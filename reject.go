@@ -0,0 +1,71 @@
+package urlshort
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// RejectReason is why a handler did not redirect a path it found.
+type RejectReason int
+
+const (
+	// RejectedNotAllowed means the target is not allowed by the
+	// AllowedHosts or AllowedSchemes options.
+	RejectedNotAllowed RejectReason = iota + 1
+	// RejectedInactive means the entry has expired or its
+	// activation window has not started yet.
+	RejectedInactive
+	// RejectedDisabled means the entry is disabled. Disabled
+	// entries only reach the fallback with the DisabledFallback
+	// option.
+	RejectedDisabled
+)
+
+func (reason RejectReason) String() string {
+	switch reason {
+	case RejectedNotAllowed:
+		return "not allowed"
+	case RejectedInactive:
+		return "inactive"
+	case RejectedDisabled:
+		return "disabled"
+	}
+	return "unknown"
+}
+
+// Rejection describes a path that was found but not redirected.
+// Target is empty unless the reason is RejectedNotAllowed.
+type Rejection struct {
+	Path   string
+	Target string
+	Reason RejectReason
+}
+
+// contextKey is the type of the context keys of this package.
+type contextKey struct {
+	name string
+}
+
+// RejectionContextKey is the context key under which handlers
+// store a Rejection in the context of requests they pass to the
+// fallback because the path was found but not redirected. The
+// fallback can read it with RejectionFromContext to tell users
+// why the link did not work.
+var RejectionContextKey = &contextKey{"rejection"}
+
+// RejectionFromContext returns the Rejection stored in ctx by a
+// handler, if any.
+func RejectionFromContext(ctx context.Context) (Rejection, bool) {
+	rejection, ok := ctx.Value(RejectionContextKey).(Rejection)
+	return rejection, ok
+}
+
+// withRejection returns r with rejection stored in its context.
+func withRejection(r *http.Request, rejection Rejection) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), RejectionContextKey, rejection))
+}
+
+// errInactive is returned by the lookupRequest method of stores
+// for paths whose entry is not active at the time of the lookup.
+var errInactive = errors.New("entry is not active")
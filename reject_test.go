@@ -0,0 +1,51 @@
+package urlshort
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRejectionFromContext(t *testing.T) {
+	var got Rejection
+	var ok bool
+	fallback := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok = RejectionFromContext(r.Context())
+		http.NotFound(w, r)
+	})
+	doc := "- path: /old\n  url: https://ok.com\n  expires: 2000-01-01T00:00:00Z\n" +
+		"- path: /off\n  url: https://ok.com\n  disabled: true\n" +
+		"- path: /ok\n  url: https://ok.com\n"
+	h, err := YAMLHandlerWithOptions([]byte(doc), fallback, Options{DisabledFallback: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hs, err := StoreHandlerWithOptions(MapStore{"/a": "https://evil.com"}, fallback, Options{AllowedHosts: []string{"ok.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		h      http.Handler
+		path   string
+		want   Rejection
+		wantOK bool
+	}{
+		{hs, "/a", Rejection{Path: "/a", Target: "https://evil.com", Reason: RejectedNotAllowed}, true},
+		{h, "/old", Rejection{Path: "/old", Reason: RejectedInactive}, true},
+		{h, "/off", Rejection{Path: "/off", Reason: RejectedDisabled}, true},
+		{h, "/nope", Rejection{}, false},
+	} {
+		got, ok = Rejection{}, false
+		serve(tc.h, http.MethodGet, tc.path)
+		if ok != tc.wantOK || got != tc.want {
+			t.Errorf("GET %s: rejection = %+v, %v, want %+v, %v", tc.path, got, ok, tc.want, tc.wantOK)
+		}
+	}
+
+	if s := RejectedDisabled.String(); s != "disabled" {
+		t.Errorf("RejectedDisabled.String() = %q", s)
+	}
+
+	h, _ = YAMLHandler([]byte(doc), fallback)
+	expectStatus(t, h, "/off", http.StatusGone)
+}
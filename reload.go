@@ -0,0 +1,85 @@
+package urlshort
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// ReloadYAMLHandler behaves like YAMLHandler but reads the YAML from the file at path.
+// Calling the returned reload function reads the file again and swaps in the new mapping.
+// If the file cannot be read or parsed, reload returns the error and the current mapping
+// keeps being served. See ReloadOnSIGHUP to trigger reloads with a signal.
+func ReloadYAMLHandler(path string, fallback http.Handler) (http.HandlerFunc, func() error, error) {
+	return ReloadYAMLHandlerWithOptions(path, fallback, Options{})
+}
+
+// ReloadYAMLHandlerWithOptions behaves like ReloadYAMLHandler but parses and serves
+// the mapping as configured by opts, on every reload.
+func ReloadYAMLHandlerWithOptions(path string, fallback http.Handler, opts Options) (http.HandlerFunc, func() error, error) {
+	return reloadHandler(path, FormatYAML, fallback, opts)
+}
+
+// ReloadJSONHandler behaves like ReloadYAMLHandler but for a JSON file.
+func ReloadJSONHandler(path string, fallback http.Handler) (http.HandlerFunc, func() error, error) {
+	return ReloadJSONHandlerWithOptions(path, fallback, Options{})
+}
+
+// ReloadJSONHandlerWithOptions behaves like ReloadYAMLHandlerWithOptions but for a
+// JSON file.
+func ReloadJSONHandlerWithOptions(path string, fallback http.Handler, opts Options) (http.HandlerFunc, func() error, error) {
+	return reloadHandler(path, FormatJSON, fallback, opts)
+}
+
+func reloadHandler(path string, format Format, fallback http.Handler, opts Options) (http.HandlerFunc, func() error, error) {
+	load := opts.fileLoader(path, format)
+	entries, err := load()
+	if err != nil {
+		return nil, nil, err
+	}
+	store := &swapStore{store: entries}
+	h, err := opts.swapHandler(store, fallback)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reload := func() error {
+		entries, err := load()
+		if err != nil {
+			return err
+		}
+		store.swap(entries)
+		return nil
+	}
+	return h, reload, nil
+}
+
+// ReloadOnSIGHUP calls reload whenever the process receives
+// SIGHUP, logging any error it returns. The returned function
+// stops listening for the signal; calling it again does nothing.
+func ReloadOnSIGHUP(reload func() error) (uninstall func()) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range signals {
+			if err := reload(); err != nil {
+				log.Printf("urlshort: reload: %v", err)
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(signals)
+			close(signals)
+			<-done
+		})
+	}
+}
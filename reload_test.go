@@ -0,0 +1,103 @@
+package urlshort
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestReloadYAMLHandler(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "r.yaml")
+	writeFile(t, f, "/a: https://a.com\n")
+	h, reload, err := ReloadYAMLHandler(f, notFound)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, f, "/b: https://b.com\n")
+	expectStatus(t, h, "/b", http.StatusNotFound)
+	if err := reload(); err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, h, "/b", http.StatusFound)
+	expectStatus(t, h, "/a", http.StatusNotFound)
+
+	writeFile(t, f, ": : :\n\t-")
+	if err := reload(); err == nil {
+		t.Error("expected an error reloading a malformed file")
+	}
+	expectStatus(t, h, "/b", http.StatusFound)
+}
+
+func TestReloadJSONHandlerMissingFile(t *testing.T) {
+	if _, _, err := ReloadJSONHandler(filepath.Join(t.TempDir(), "missing.json"), notFound); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestReloadEntries(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "r.yaml")
+	writeFile(t, f, "- path: /a\n  url: https://a.com\n  status: 301\n  headers: {X-A: one}\n"+
+		"- path: /old\n  url: https://o.com\n  expires: 2000-01-01T00:00:00Z\n"+
+		"- path: /d\n  url: https://d.com\n  disabled: true\n")
+	h, reload, err := ReloadYAMLHandler(f, notFound)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := serve(h, http.MethodGet, "/a"); w.Code != http.StatusMovedPermanently || w.Header().Get("X-A") != "one" {
+		t.Errorf("GET /a = %d %v, want the entry's status and headers", w.Code, w.Header())
+	}
+	expectStatus(t, h, "/old", http.StatusNotFound)
+	expectStatus(t, h, "/d", http.StatusGone)
+
+	writeFile(t, f, "- path: /b\n  url: https://b.com\n")
+	if err := reload(); err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, h, "/a", http.StatusNotFound)
+	expectStatus(t, h, "/b", http.StatusFound)
+}
+
+func TestReloadOnSIGHUP(t *testing.T) {
+	reloaded := make(chan struct{}, 1)
+	uninstall := ReloadOnSIGHUP(func() error {
+		reloaded <- struct{}{}
+		return nil
+	})
+	defer uninstall()
+
+	if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-reloaded:
+	case <-time.After(5 * time.Second):
+		t.Fatal("SIGHUP did not trigger a reload")
+	}
+
+	// The deferred call uninstalls a second time, which must not panic.
+	uninstall()
+}
+
+func TestReloadHandlerOptions(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "r.json")
+	writeFile(t, f, `{"/a": "https://a.com"}`)
+	h, reload, err := ReloadJSONHandlerWithOptions(f, notFound, Options{Status: http.StatusSeeOther, StrictFields: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectRedirect(t, h, "/a", http.StatusSeeOther, "https://a.com")
+
+	writeFile(t, f, `[{"path": "/a", "urls": "https://x.com"}]`)
+	if err := reload(); err == nil {
+		t.Error("expected a reload with an unknown field to fail")
+	}
+	writeFile(t, f, `[{"path": "/b", "url": "https://b.com"}]`)
+	if err := reload(); err != nil {
+		t.Fatal(err)
+	}
+	expectRedirect(t, h, "/b", http.StatusSeeOther, "https://b.com")
+}
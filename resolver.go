@@ -0,0 +1,79 @@
+package urlshort
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Resolver is a Store combining exact and prefix redirects with
+// fixed precedence, instead of relying on the order handlers are
+// nested in: an exact match always wins, then the longest
+// matching prefix. Rules may be added while the Resolver is
+// serving lookups.
+type Resolver struct {
+	mu       sync.RWMutex
+	exact    map[string]string
+	prefixes []prefixRule // longest first
+}
+
+// NewResolver returns an empty Resolver.
+func NewResolver() *Resolver {
+	return &Resolver{exact: make(map[string]string)}
+}
+
+// AddExact redirects path to url, replacing any earlier exact
+// rule for path.
+func (r *Resolver) AddExact(path, url string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.exact[path] = url
+}
+
+// AddPrefix redirects prefix and every path below it to url, with
+// the rest of the path appended, so with the prefix /gh the path
+// /gh/repo/issues redirects to url + "/repo/issues". Prefixes
+// match whole path segments only, so /gh does not match /ghost.
+// An earlier prefix rule for the same prefix is replaced.
+func (r *Resolver) AddPrefix(prefix, url string) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, rule := range r.prefixes {
+		if rule.prefix == prefix {
+			r.prefixes[i].url = url
+			return
+		}
+	}
+	r.prefixes = append(r.prefixes, prefixRule{prefix: prefix, url: url})
+	sort.SliceStable(r.prefixes, func(i, j int) bool {
+		return len(r.prefixes[i].prefix) > len(r.prefixes[j].prefix)
+	})
+}
+
+// Lookup returns the exact match for path if there is one, and
+// otherwise the target of the longest prefix matching path with
+// the rest of the path appended.
+func (r *Resolver) Lookup(path string) (string, bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if url, ok := r.exact[path]; ok {
+		return url, true, nil
+	}
+	for _, rule := range r.prefixes {
+		if path == rule.prefix {
+			return rule.url, true, nil
+		}
+		if strings.HasPrefix(path, rule.prefix+"/") {
+			return joinRemainder(rule.url, strings.TrimPrefix(path, rule.prefix+"/")), true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// Handler will return an http.HandlerFunc that redirects the
+// paths matched by r and calls fallback for the others.
+func (r *Resolver) Handler(fallback http.Handler) http.HandlerFunc {
+	return StoreHandler(r, fallback)
+}
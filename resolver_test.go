@@ -0,0 +1,30 @@
+package urlshort
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestResolver(t *testing.T) {
+	r := NewResolver()
+	r.AddPrefix("/gh/", "https://github.com")
+	r.AddPrefix("/gh/go", "https://go.dev/")
+	r.AddExact("/gh/go/x", "https://exact.com")
+	h := r.Handler(notFound)
+	for path, want := range map[string]string{
+		"/gh/go/x":   "https://exact.com",
+		"/gh/go/y/z": "https://go.dev/y/z",
+		"/gh/go":     "https://go.dev/",
+		"/gh/rust":   "https://github.com/rust",
+		"/gh":        "https://github.com",
+		"/gh/gopher": "https://github.com/gopher",
+	} {
+		expectRedirect(t, h, path, http.StatusFound, want)
+	}
+	expectStatus(t, h, "/ghost", http.StatusNotFound)
+
+	// Rules added after the handler was built take effect, and a
+	// prefix added again replaces the earlier one.
+	r.AddPrefix("/gh", "https://gitlab.com")
+	expectRedirect(t, h, "/gh/a", http.StatusFound, "https://gitlab.com/a")
+}
@@ -0,0 +1,36 @@
+package urlshort
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// ReverseLookup returns the paths in pathsToUrls that redirect to
+// exactly target, sorted.
+func ReverseLookup(pathsToUrls map[string]string, target string) []string {
+	return reverseLookup(pathsToUrls, func(u string) bool {
+		return u == target
+	})
+}
+
+// ReverseLookupHost returns the paths in pathsToUrls whose target
+// is on host, sorted. Hosts are compared without regard to case
+// or port.
+func ReverseLookupHost(pathsToUrls map[string]string, host string) []string {
+	return reverseLookup(pathsToUrls, func(target string) bool {
+		u, err := url.Parse(target)
+		return err == nil && strings.EqualFold(u.Hostname(), host)
+	})
+}
+
+func reverseLookup(pathsToUrls map[string]string, match func(target string) bool) []string {
+	var paths []string
+	for path, target := range pathsToUrls {
+		if match(target) {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
@@ -0,0 +1,23 @@
+package urlshort
+
+import (
+	"testing"
+)
+
+func TestReverseLookup(t *testing.T) {
+	m := map[string]string{
+		"/a": "https://old.com/x",
+		"/b": "https://old.com/x",
+		"/c": "https://OLD.com:8080/y",
+		"/d": "https://new.com",
+	}
+	if got := ReverseLookup(m, "https://old.com/x"); len(got) != 2 || got[0] != "/a" || got[1] != "/b" {
+		t.Errorf("ReverseLookup = %v, want [/a /b]", got)
+	}
+	if got := ReverseLookup(m, "https://none.com"); got != nil {
+		t.Errorf("ReverseLookup of an unused URL = %v, want nil", got)
+	}
+	if got := ReverseLookupHost(m, "old.com"); len(got) != 3 {
+		t.Errorf("ReverseLookupHost = %v, want /a /b /c", got)
+	}
+}
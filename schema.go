@@ -0,0 +1,153 @@
+package urlshort
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// JSONSchema is a JSON Schema, draft 7, describing JSON mapping
+// documents: either an object mapping paths to urls, or an array
+// of entries as described by YAMLHandler. Editors and CI can use
+// it to check documents; ValidateAgainstSchema checks one here.
+//
+// The schema is stricter than the parsers in two ways: it rejects
+// fields it does not know, which the parsers ignore by default,
+// and it only accepts lower-case device names.
+const JSONSchema = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "$id": "https://github.com/bcpoole/urlshort/schema.json",
+  "title": "urlshort mapping document",
+  "if": {"type": "array"},
+  "then": {
+    "items": {"$ref": "#/definitions/entry"}
+  },
+  "else": {
+    "type": "object",
+    "additionalProperties": {"type": "string"}
+  },
+  "definitions": {
+    "entry": {
+      "type": "object",
+      "properties": {
+        "path": {"type": "string", "pattern": "^/"},
+        "paths": {
+          "type": "array",
+          "items": {"type": "string", "pattern": "^/"},
+          "minItems": 1
+        },
+        "url": {"type": "string"},
+        "targets": {
+          "type": "array",
+          "items": {"$ref": "#/definitions/target"},
+          "minItems": 1
+        },
+        "expires": {"type": "string", "format": "date-time"},
+        "active_from": {"type": "string", "format": "date-time"},
+        "active_until": {"type": "string", "format": "date-time"},
+        "utm": {"$ref": "#/definitions/strings"},
+        "devices": {
+          "type": "object",
+          "additionalProperties": {"type": "string"},
+          "propertyNames": {"enum": ["desktop", "mobile", "tablet"]}
+        },
+        "languages": {"$ref": "#/definitions/strings"},
+        "countries": {"$ref": "#/definitions/strings"},
+        "status": {"enum": [301, 302, 303, 307, 308]},
+        "headers": {
+          "type": "object",
+          "additionalProperties": {"type": "string"},
+          "patternProperties": {"^[Ll][Oo][Cc][Aa][Tt][Ii][Oo][Nn]$": false}
+        },
+        "disabled": {"type": "boolean"},
+        "disabled_message": {"type": "string"}
+      },
+      "additionalProperties": false,
+      "anyOf": [
+        {"required": ["path"]},
+        {"required": ["paths"]}
+      ],
+      "dependencies": {
+        "targets": {"properties": {"url": false}}
+      }
+    },
+    "target": {
+      "type": "object",
+      "properties": {
+        "url": {"type": "string"},
+        "weight": {"type": "integer", "minimum": 1}
+      },
+      "required": ["url", "weight"],
+      "additionalProperties": false
+    },
+    "strings": {
+      "type": "object",
+      "additionalProperties": {"type": "string"}
+    }
+  }
+}`
+
+var (
+	compileSchemaOnce sync.Once
+	compiledSchema    *jsonschema.Schema
+	compileSchemaErr  error
+)
+
+// ValidateAgainstSchema checks the JSON mapping document data
+// against JSONSchema. A document that does not match returns a
+// *SchemaError listing every violation found.
+func ValidateAgainstSchema(data []byte) error {
+	compileSchemaOnce.Do(func() {
+		const url = "https://github.com/bcpoole/urlshort/schema.json"
+		c := jsonschema.NewCompiler()
+		if compileSchemaErr = c.AddResource(url, strings.NewReader(JSONSchema)); compileSchemaErr != nil {
+			return
+		}
+		compiledSchema, compileSchemaErr = c.Compile(url)
+	})
+	if compileSchemaErr != nil {
+		return compileSchemaErr
+	}
+
+	var doc interface{}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(&doc); err != nil {
+		return err
+	}
+	err := compiledSchema.Validate(doc)
+	if ve, ok := err.(*jsonschema.ValidationError); ok {
+		return &SchemaError{Violations: schemaViolations(ve, nil)}
+	}
+	return err
+}
+
+// SchemaError is returned by ValidateAgainstSchema for documents
+// that do not match JSONSchema. Each violation names the location
+// in the document it concerns, as a JSON pointer such as /0/url.
+type SchemaError struct {
+	Violations []string
+}
+
+func (e *SchemaError) Error() string {
+	return "schema: " + strings.Join(e.Violations, "; ")
+}
+
+// schemaViolations appends the innermost causes of ve, which are
+// the most precise, to violations.
+func schemaViolations(ve *jsonschema.ValidationError, violations []string) []string {
+	if len(ve.Causes) == 0 {
+		location := ve.InstanceLocation
+		if location == "" {
+			location = "/"
+		}
+		return append(violations, location+": "+ve.Message)
+	}
+	for _, cause := range ve.Causes {
+		violations = schemaViolations(cause, violations)
+	}
+	return violations
+}
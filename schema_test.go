@@ -0,0 +1,41 @@
+package urlshort
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestValidateAgainstSchema(t *testing.T) {
+	for _, doc := range []string{
+		`[{"path": "/a", "url": "https://a.com", "expires": "2030-01-01T00:00:00Z", "disabled": true, "status": 307},
+		  {"paths": ["/b", "/c"], "targets": [{"url": "https://b.com", "weight": 2}], "devices": {"mobile": "https://m.com"}}]`,
+		`{"/a": "https://a.com"}`,
+	} {
+		if err := ValidateAgainstSchema([]byte(doc)); err != nil {
+			t.Errorf("%s: %v", doc, err)
+		}
+	}
+
+	for _, doc := range []string{
+		`[{"path": "/a", "url": 5}]`,
+		`[{"path": "/a", "urls": "https://a.com"}]`,
+		`[{"path": "/a", "url": "x", "expires": "tomorrow"}]`,
+		`[{"path": "/a", "url": "x", "targets": [{"url": "y", "weight": 1}]}]`,
+		`[{"path": "/a", "targets": [{"url": "y", "weight": 0}]}]`,
+		`[{"path": "/a", "url": "x", "headers": {"Location": "y"}}]`,
+		`[{"url": "x"}]`,
+		`{"/a": 1}`,
+		`[`,
+	} {
+		if err := ValidateAgainstSchema([]byte(doc)); err == nil {
+			t.Errorf("%s: expected a schema error", doc)
+		}
+	}
+}
+
+func TestJSONSchemaIsJSON(t *testing.T) {
+	var schema interface{}
+	if err := json.Unmarshal([]byte(JSONSchema), &schema); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,86 @@
+package urlshort
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// DefaultSearchLimit and MaxSearchLimit are the default and the
+// largest number of results returned by a SearchHandler.
+const (
+	DefaultSearchLimit = 50
+	MaxSearchLimit     = 1000
+)
+
+// SearchHandler will return an http.Handler answering
+// GET /admin/search?q=promo with the mappings of store whose path
+// or url contains q, ignoring case, as a JSON array of
+// {"path": ..., "url": ...} objects in path order. An empty q
+// matches every mapping.
+//
+// Results are paged by the limit and offset parameters: limit
+// defaults to DefaultSearchLimit and may not exceed
+// MaxSearchLimit. The X-Total-Count header holds the number of
+// matches before paging.
+//
+// Like AdminHandler, the handler performs no authentication.
+func SearchHandler(store ListableStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/admin/search" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		query := r.URL.Query()
+		limit, ok := searchParam(query.Get("limit"), DefaultSearchLimit)
+		if !ok || limit > MaxSearchLimit {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		offset, ok := searchParam(query.Get("offset"), 0)
+		if !ok {
+			http.Error(w, "invalid offset", http.StatusBadRequest)
+			return
+		}
+
+		q := strings.ToLower(query.Get("q"))
+		links := []entry{}
+		total := 0
+		err := store.Each(func(path, url string) error {
+			if !strings.Contains(strings.ToLower(path), q) && !strings.Contains(strings.ToLower(url), q) {
+				return nil
+			}
+			if total >= offset && len(links) < limit {
+				links = append(links, entry{Path: path, URL: url})
+			}
+			total++
+			return nil
+		})
+		if err == errNotListable {
+			http.Error(w, err.Error(), http.StatusNotImplemented)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("X-Total-Count", strconv.Itoa(total))
+		writeJSON(w, http.StatusOK, links)
+	})
+}
+
+// searchParam parses the non-negative integer parameter value,
+// returning def if it is empty. It reports false if value is
+// invalid.
+func searchParam(value string, def int) (int, bool) {
+	if value == "" {
+		return def, true
+	}
+	n, err := strconv.Atoi(value)
+	return n, err == nil && n >= 0
+}
@@ -0,0 +1,61 @@
+package urlshort
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSearchHandler(t *testing.T) {
+	h := SearchHandler(MapStore{
+		"/promo":   "https://a.com",
+		"/b":       "https://PROMO.com",
+		"/c":       "https://c.com",
+		"/d-promo": "https://d.com",
+	})
+	search := func(query string) ([]entry, *httptest.ResponseRecorder) {
+		t.Helper()
+		w := serve(h, http.MethodGet, "/admin/search?"+query)
+		var out []entry
+		if w.Code == http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+				t.Fatalf("decode %q: %v", w.Body, err)
+			}
+		}
+		return out, w
+	}
+	paths := func(es []entry) []string {
+		var ps []string
+		for _, e := range es {
+			ps = append(ps, e.Path)
+		}
+		return ps
+	}
+
+	out, w := search("q=Promo")
+	if p := paths(out); len(p) != 3 || p[0] != "/b" || p[1] != "/d-promo" || p[2] != "/promo" {
+		t.Errorf("q=Promo found %v", p)
+	}
+	if n := w.Header().Get("X-Total-Count"); n != "3" {
+		t.Errorf("X-Total-Count = %q, want 3", n)
+	}
+	if out, _ := search("q=c.com"); len(out) != 1 || out[0].Path != "/c" {
+		t.Errorf("q=c.com found %v", paths(out))
+	}
+	out, w = search("q=promo&limit=1&offset=1")
+	if len(out) != 1 || out[0].Path != "/d-promo" || w.Header().Get("X-Total-Count") != "3" {
+		t.Errorf("second page = %v, total %s", paths(out), w.Header().Get("X-Total-Count"))
+	}
+	if out, _ := search("q=promo&offset=10"); out == nil || len(out) != 0 {
+		t.Errorf("page past the end = %#v, want an empty list", out)
+	}
+	if out, _ := search("limit=0"); len(out) != 0 {
+		t.Errorf("limit=0 = %v", paths(out))
+	}
+	for _, query := range []string{"limit=-1", "limit=1001", "offset=x"} {
+		if _, w := search(query); w.Code != http.StatusBadRequest {
+			t.Errorf("%s = %d, want 400", query, w.Code)
+		}
+	}
+}
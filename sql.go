@@ -0,0 +1,265 @@
+package urlshort
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// createSQLiteTable creates the table SQLiteHandler reads from.
+// Rows inserted without a created time get the current one.
+const createSQLiteTable = `CREATE TABLE IF NOT EXISTS redirects (path TEXT PRIMARY KEY, url TEXT, created TIMESTAMP DEFAULT CURRENT_TIMESTAMP)`
+
+// selectSQLiteCreatedColumn fails if the redirects table predates
+// its created column.
+const selectSQLiteCreatedColumn = `SELECT created FROM redirects LIMIT 0`
+
+// addSQLiteCreatedColumn adds the created column to a redirects
+// table that predates it. SQLite does not allow the column to
+// default to the current time when added later.
+const addSQLiteCreatedColumn = `ALTER TABLE redirects ADD COLUMN created TIMESTAMP`
+
+// selectSQLiteURL looks up the url for a path in the redirects table.
+const selectSQLiteURL = `SELECT url FROM redirects WHERE path = ?`
+
+// selectSQLiteAll lists the redirects table in path order.
+const selectSQLiteAll = `SELECT path, url FROM redirects ORDER BY path`
+
+// selectSQLiteCreated looks up the creation time of a path.
+const selectSQLiteCreated = `SELECT created FROM redirects WHERE path = ?`
+
+// selectSQLiteAllCreated lists the creation times of the redirects
+// table in path order.
+const selectSQLiteAllCreated = `SELECT path, created FROM redirects ORDER BY path`
+
+// errNoCreated is returned by the creation time methods of a
+// SQLStore without the queries they need.
+var errNoCreated = errors.New("store does not record creation times")
+
+// SQLStore is a Store that resolves paths with a prepared SQL
+// query taking the path as its only parameter and returning the
+// url as its only column.
+type SQLStore struct {
+	db          *sql.DB
+	lookup      *sql.Stmt
+	list        *sql.Stmt
+	created     *sql.Stmt
+	listCreated *sql.Stmt
+}
+
+// SQLStoreOptions configures a SQLStore.
+type SQLStoreOptions struct {
+	// ListQuery selects every mapping as its path and url columns,
+	// such as
+	//
+	//     SELECT slug, target FROM links ORDER BY slug
+	//
+	// Each runs it to list the store, which fails if it is empty.
+	// Ordering the rows keeps listings and exports deterministic.
+	ListQuery string
+
+	// CreatedQuery selects the creation time of the mapping for a
+	// path, taking the path as its only parameter, such as
+	//
+	//     SELECT created_at FROM links WHERE slug = $1
+	//
+	// Created runs it, which fails if it is empty. A NULL time is
+	// reported as unknown.
+	CreatedQuery string
+
+	// ListCreatedQuery selects every mapping as its path and
+	// creation time columns, such as
+	//
+	//     SELECT slug, created_at FROM links ORDER BY slug
+	//
+	// EachCreated runs it, which fails if it is empty.
+	ListCreatedQuery string
+}
+
+// NewSQLStore returns a SQLStore that resolves paths with query,
+// which is prepared on db. The query must take the path as its
+// only parameter, using the placeholder syntax of the driver, and
+// select the url as its only column, such as
+//
+//     SELECT target FROM links WHERE slug = $1
+func NewSQLStore(db *sql.DB, query string) (*SQLStore, error) {
+	return NewSQLStoreWithOptions(db, query, SQLStoreOptions{})
+}
+
+// NewSQLStoreWithOptions behaves like NewSQLStore but configures
+// the store as described by opts.
+func NewSQLStoreWithOptions(db *sql.DB, query string, opts SQLStoreOptions) (*SQLStore, error) {
+	lookup, err := db.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	s := &SQLStore{db: db, lookup: lookup}
+	for _, q := range []struct {
+		query string
+		stmt  **sql.Stmt
+	}{
+		{opts.ListQuery, &s.list},
+		{opts.CreatedQuery, &s.created},
+		{opts.ListCreatedQuery, &s.listCreated},
+	} {
+		if q.query == "" {
+			continue
+		}
+		if *q.stmt, err = db.Prepare(q.query); err != nil {
+			s.Close()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// NewSQLiteStore creates the redirects table in db if needed and
+// returns a SQLStore reading from it, which lists the table in
+// path order. The table records the time each row is inserted in
+// its created column, which is added to tables created before it
+// existed; rows already there have no creation time.
+func NewSQLiteStore(db *sql.DB) (*SQLStore, error) {
+	if _, err := db.Exec(createSQLiteTable); err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(selectSQLiteCreatedColumn); err != nil {
+		if _, err := db.Exec(addSQLiteCreatedColumn); err != nil {
+			return nil, err
+		}
+	}
+	return NewSQLStoreWithOptions(db, selectSQLiteURL, SQLStoreOptions{
+		ListQuery:        selectSQLiteAll,
+		CreatedQuery:     selectSQLiteCreated,
+		ListCreatedQuery: selectSQLiteAllCreated,
+	})
+}
+
+// Lookup runs the store's query for path.
+func (s *SQLStore) Lookup(path string) (string, bool, error) {
+	return s.LookupContext(context.Background(), path)
+}
+
+// LookupContext runs the store's query for path, cancelling it
+// when ctx is done.
+func (s *SQLStore) LookupContext(ctx context.Context, path string) (string, bool, error) {
+	var url string
+	err := s.lookup.QueryRowContext(ctx, path).Scan(&url)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return url, true, nil
+}
+
+// Each calls fn for every mapping selected by the list query of
+// the store, in the order of its rows, stopping at the first error
+// fn returns. It fails if the store has no list query; see
+// SQLStoreOptions.
+func (s *SQLStore) Each(fn func(path, url string) error) error {
+	if s.list == nil {
+		return errNotListable
+	}
+	rows, err := s.list.Query()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var path, url string
+		if err := rows.Scan(&path, &url); err != nil {
+			return err
+		}
+		if err := fn(path, url); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Created runs the creation query of the store for path. ok is
+// false if path has no row; a NULL time is returned as zero. It
+// fails if the store has no creation query; see SQLStoreOptions.
+func (s *SQLStore) Created(path string) (time.Time, bool, error) {
+	if s.created == nil {
+		return time.Time{}, false, errNoCreated
+	}
+	var created sql.NullTime
+	err := s.created.QueryRow(path).Scan(&created)
+	if err == sql.ErrNoRows {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return created.Time, true, nil
+}
+
+// EachCreated calls fn for every row selected by the creation
+// list query of the store, in the order of its rows, passing a
+// NULL time as zero and stopping at the first error fn returns.
+// It fails if the store has no such query; see SQLStoreOptions.
+func (s *SQLStore) EachCreated(fn func(path string, created time.Time) error) error {
+	if s.listCreated == nil {
+		return errNoCreated
+	}
+	rows, err := s.listCreated.Query()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var path string
+		var created sql.NullTime
+		if err := rows.Scan(&path, &created); err != nil {
+			return err
+		}
+		if err := fn(path, created.Time); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Ping checks that the database can be reached.
+func (s *SQLStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// Close releases the prepared statements. The database itself is
+// left open.
+func (s *SQLStore) Close() error {
+	for _, stmt := range []*sql.Stmt{s.list, s.created, s.listCreated} {
+		if stmt != nil {
+			stmt.Close()
+		}
+	}
+	return s.lookup.Close()
+}
+
+// SQLiteHandler reads url handler mappings from the redirects(path, url) table of a
+// SQLite database, creating the table if it does not exist, and redirects based on
+// those inputs. Else falls back to provided Handler. Query errors are logged and
+// also fall back.
+func SQLiteHandler(db *sql.DB, fallback http.Handler) (http.HandlerFunc, error) {
+	store, err := NewSQLiteStore(db)
+	if err != nil {
+		return nil, err
+	}
+	return StoreHandler(store, fallback), nil
+}
+
+// SQLHandler resolves each request path with query, which takes the path as its only
+// parameter and returns the url as its only column, and redirects based on the result.
+// It works with any database/sql driver, such as MySQL or Postgres. Else falls back to
+// provided Handler, including when the query fails, in which case the error is logged.
+func SQLHandler(db *sql.DB, query string, fallback http.Handler) (http.HandlerFunc, error) {
+	store, err := NewSQLStore(db, query)
+	if err != nil {
+		return nil, err
+	}
+	return StoreHandler(store, fallback), nil
+}
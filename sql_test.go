@@ -0,0 +1,155 @@
+package urlshort
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	_ "modernc.org/sqlite"
+)
+
+// openTestSQLite opens an in-memory SQLite database that is closed
+// when the test ends.
+func openTestSQLite(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every connection would get its own in-memory database.
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestSQLiteHandler(t *testing.T) {
+	db := openTestSQLite(t)
+	h, err := SQLiteHandler(db, notFound)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO redirects (path, url) VALUES ('/s', 'https://s.com')`); err != nil {
+		t.Fatal(err)
+	}
+	expectRedirect(t, h, "/s", http.StatusFound, "https://s.com")
+	expectStatus(t, h, "/x", http.StatusNotFound)
+
+	if _, err := db.Exec(`DROP TABLE redirects`); err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, h, "/s", http.StatusNotFound)
+}
+
+func TestSQLHandler(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	prep := mock.ExpectPrepare(`SELECT target FROM links WHERE slug = \$1`)
+	h, err := SQLHandler(db, "SELECT target FROM links WHERE slug = $1", notFound)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prep.ExpectQuery().WithArgs("/a").WillReturnRows(sqlmock.NewRows([]string{"target"}).AddRow("https://a.com"))
+	prep.ExpectQuery().WithArgs("/b").WillReturnRows(sqlmock.NewRows([]string{"target"}))
+	prep.ExpectQuery().WithArgs("/c").WillReturnError(errors.New("connection reset"))
+
+	expectRedirect(t, h, "/a", http.StatusFound, "https://a.com")
+	expectStatus(t, h, "/b", http.StatusNotFound)
+	expectStatus(t, h, "/c", http.StatusNotFound)
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSQLHandlerPrepareError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	mock.ExpectPrepare("SELECT").WillReturnError(errors.New("syntax error"))
+	if _, err := SQLHandler(db, "SELECT", notFound); err == nil {
+		t.Error("expected the prepare error")
+	}
+}
+
+func TestSQLStoreEach(t *testing.T) {
+	db := openTestSQLite(t)
+	s, err := NewSQLiteStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO redirects (path, url) VALUES ('/b', 'https://b.com'), ('/a', 'https://a.com')`); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	if err := s.Each(func(path, url string) error {
+		got = append(got, path+"="+url)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if s := strings.Join(got, ","); s != "/a=https://a.com,/b=https://b.com" {
+		t.Errorf("Each = %s", s)
+	}
+
+	plain, err := NewSQLStore(db, selectSQLiteURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := plain.Each(func(string, string) error { return nil }); err != errNotListable {
+		t.Errorf("Each without a list query = %v, want %v", err, errNotListable)
+	}
+	expectStatus(t, AdminHandler(plain), "/admin/links", http.StatusNotImplemented)
+}
+
+func TestSQLStoreCreated(t *testing.T) {
+	db := openTestSQLite(t)
+	// A table created before the created column was added.
+	db.Exec(`CREATE TABLE redirects (path TEXT PRIMARY KEY, url TEXT)`)
+	db.Exec(`INSERT INTO redirects VALUES ('/old', 'https://o.com')`)
+	s, err := NewSQLiteStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO redirects (path, url, created) VALUES ('/new', 'https://n.com', ?)`, time.Now().Add(-48*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	if c, ok, err := s.Created("/old"); err != nil || !ok || !c.IsZero() {
+		t.Errorf("Created(/old) = %v, %v, %v, want the zero time", c, ok, err)
+	}
+	if c, ok, err := s.Created("/new"); err != nil || !ok || c.IsZero() {
+		t.Errorf("Created(/new) = %v, %v, %v", c, ok, err)
+	}
+	if _, ok, _ := s.Created("/none"); ok {
+		t.Error("Created(/none) reported a missing path")
+	}
+	old, err := CreatedBefore(s, time.Now().Add(-time.Hour))
+	if err != nil || len(old) != 1 || old[0].Path != "/new" {
+		t.Errorf("CreatedBefore = %v, %v, want /new", old, err)
+	}
+}
+
+func TestSQLStoreCreatedDefault(t *testing.T) {
+	db := openTestSQLite(t)
+	s, err := NewSQLiteStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Exec(`INSERT INTO redirects (path, url) VALUES ('/x', 'https://x.com')`)
+	if c, ok, err := s.Created("/x"); err != nil || !ok || time.Since(c) > time.Minute {
+		t.Errorf("Created(/x) = %v, %v, %v, want about now", c, ok, err)
+	}
+
+	plain, _ := NewSQLStore(db, selectSQLiteURL)
+	if _, _, err := plain.Created("/x"); err == nil {
+		t.Error("expected an error without a created query")
+	}
+}
@@ -0,0 +1,496 @@
+package urlshort
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Store is implemented by anything that can resolve a request
+// path to the URL it should redirect to. Lookup reports ok as
+// false when the path is unknown, and a non-nil error only when
+// the underlying storage could not be queried or the path has
+// been disabled; see DisabledError.
+type Store interface {
+	Lookup(path string) (url string, ok bool, err error)
+}
+
+// DisabledError is returned by Lookup for a path whose redirect
+// has been disabled. The handlers answer such paths with 410 Gone
+// and Message, if any, rather than calling the fallback, unless
+// the DisabledFallback option is set.
+type DisabledError struct {
+	Path    string
+	Message string
+}
+
+func (e *DisabledError) Error() string {
+	return fmt.Sprintf("%s is disabled", e.Path)
+}
+
+// ContextStore is a Store whose lookups can be cancelled. The
+// handlers call LookupContext with the request context when a
+// Store implements it, so lookups are abandoned once the client
+// goes away or the request deadline passes.
+type ContextStore interface {
+	Store
+	LookupContext(ctx context.Context, path string) (url string, ok bool, err error)
+}
+
+// lookupContext looks up path in store, passing ctx along if
+// store is a ContextStore.
+func lookupContext(ctx context.Context, store Store, path string) (string, bool, error) {
+	if cs, ok := store.(ContextStore); ok {
+		return cs.LookupContext(ctx, path)
+	}
+	if err := ctx.Err(); err != nil {
+		return "", false, err
+	}
+	return store.Lookup(path)
+}
+
+// Peeker is implemented by stores whose Lookup has side effects,
+// such as a BoltStore consuming single-use redirects or counting
+// hits. Peek resolves path like Lookup but leaves the store as it
+// is, for callers that only check whether a path exists.
+type Peeker interface {
+	Peek(path string) (url string, ok bool, err error)
+}
+
+// peek looks up path in store without side effects if store is a
+// Peeker, or with Lookup otherwise.
+func peek(store Store, path string) (string, bool, error) {
+	if p, ok := store.(Peeker); ok {
+		return p.Peek(path)
+	}
+	return store.Lookup(path)
+}
+
+// SingleUseStore is implemented by stores that can hold redirects
+// that work only once, such as a BoltStore. Reveal resolves path
+// like Peek but reports single-use redirects as missing, for
+// callers that show or answer for a path without redirecting it:
+// telling the target of a single-use redirect would give it away
+// without using it up.
+type SingleUseStore interface {
+	Peeker
+	Reveal(path string) (url string, ok bool, err error)
+}
+
+// Reveal looks up path in store on behalf of a caller that does
+// not redirect it, such as a preview or a HEAD request: without
+// side effects, and without finding single-use redirects. See
+// SingleUseStore.
+func Reveal(store Store, path string) (string, bool, error) {
+	if s, ok := store.(SingleUseStore); ok {
+		return s.Reveal(path)
+	}
+	return peek(store, path)
+}
+
+// requestStore is implemented by stores whose targets depend on
+// the request being redirected, such as entries with per-device
+// targets.
+type requestStore interface {
+	lookupRequest(r *http.Request, path string) (string, bool, error)
+}
+
+// lookupRequest looks up path in store on behalf of r.
+func lookupRequest(r *http.Request, store Store, path string) (string, bool, error) {
+	if rs, ok := store.(requestStore); ok {
+		return rs.lookupRequest(r, path)
+	}
+	return lookupContext(r.Context(), store, path)
+}
+
+// responseStore is implemented by stores whose entries may
+// customize their redirect response.
+type responseStore interface {
+	redirectResponse(path string) response
+}
+
+// response describes the redirect response for a path. A zero
+// status means the status of the handler, and headers are set in
+// addition to those of the handler.
+type response struct {
+	status  int
+	headers map[string]string
+}
+
+// MapStore is an in-memory Store backed by a mapping of paths
+// to urls.
+type MapStore map[string]string
+
+// Lookup returns the url mapped to path, if any.
+func (m MapStore) Lookup(path string) (string, bool, error) {
+	url, ok := m[path]
+	return url, ok, nil
+}
+
+// Each calls fn for every mapping in m, in path order, stopping
+// at the first error fn returns.
+func (m MapStore) Each(fn func(path, url string) error) error {
+	for _, e := range mapEntries(m) {
+		if err := fn(e.Path, e.URL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MutableMapStore is an in-memory Store whose mappings can be
+// changed while it is serving lookups. The zero value is an empty
+// store ready to use.
+type MutableMapStore struct {
+	mu    sync.RWMutex
+	paths map[string]string
+}
+
+// NewMutableMapStore returns a MutableMapStore holding a copy of
+// pathsToUrls.
+func NewMutableMapStore(pathsToUrls map[string]string) *MutableMapStore {
+	paths := make(map[string]string, len(pathsToUrls))
+	for path, url := range pathsToUrls {
+		paths[path] = url
+	}
+	return &MutableMapStore{paths: paths}
+}
+
+// Lookup returns the url mapped to path, if any.
+func (m *MutableMapStore) Lookup(path string) (string, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	url, ok := m.paths[path]
+	return url, ok, nil
+}
+
+// Put maps path to url, replacing any existing mapping for path.
+func (m *MutableMapStore) Put(path, url string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.paths == nil {
+		m.paths = make(map[string]string)
+	}
+	m.paths[path] = url
+	return nil
+}
+
+// Delete removes the mapping for path, if any.
+func (m *MutableMapStore) Delete(path string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.paths, path)
+	return nil
+}
+
+// Each calls fn for every mapping in path order, stopping at the
+// first error fn returns. fn sees the mappings as they were when
+// Each was called and may change the store.
+func (m *MutableMapStore) Each(fn func(path, url string) error) error {
+	m.mu.RLock()
+	entries := mapEntries(m.paths)
+	m.mu.RUnlock()
+	for _, e := range entries {
+		if err := fn(e.Path, e.URL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// entryStore is a Store built from the entries of a mapping
+// document. Expired entries are treated as missing.
+type entryStore struct {
+	redirects map[string]redirect
+	globs     []globPattern // keys of redirects with wildcards, most specific first
+	now       func() time.Time
+	geo       geoLookup
+
+	mu  sync.Mutex // guards rng
+	rng *rand.Rand
+
+	overrideUTM bool
+}
+
+// redirect is the parsed form of an entry. Zero times leave the
+// corresponding end of the activation window open.
+type redirect struct {
+	url         string
+	activeFrom  time.Time
+	activeUntil time.Time
+
+	targets     []weightedTarget
+	totalWeight int
+	devices     map[Device]string
+	languages   *languageTargets
+	countries   map[string]string
+
+	utm  map[string]string
+	resp response
+
+	disabled        bool
+	disabledMessage string
+}
+
+// parseEntry validates the optional fields of e.
+func parseEntry(e entry) (redirect, error) {
+	r := redirect{
+		url:             e.URL,
+		targets:         e.Targets,
+		resp:            response{status: e.Status, headers: e.Headers},
+		disabled:        e.Disabled,
+		disabledMessage: e.DisabledMessage,
+	}
+	if len(e.Targets) > 0 && e.URL != "" {
+		return r, fmt.Errorf("entry for %s sets both url and targets", e.Path)
+	}
+	if e.Status != 0 {
+		if err := checkRedirectStatus(e.Status); err != nil {
+			return r, fmt.Errorf("status for %s: %s", e.Path, err)
+		}
+	}
+	if err := checkHeaders(e.Headers); err != nil {
+		return r, fmt.Errorf("headers for %s: %s", e.Path, err)
+	}
+	for _, t := range e.Targets {
+		if t.Weight <= 0 {
+			return r, fmt.Errorf("target %s for %s must have a positive weight", t.URL, e.Path)
+		}
+		r.totalWeight += t.Weight
+	}
+	for name, url := range e.Devices {
+		device, err := parseDevice(name)
+		if err != nil {
+			return r, fmt.Errorf("devices for %s: %s", e.Path, err)
+		}
+		if r.devices == nil {
+			r.devices = make(map[Device]string)
+		}
+		r.devices[device] = url
+	}
+	for code, url := range e.Countries {
+		if r.countries == nil {
+			r.countries = make(map[string]string)
+		}
+		r.countries[strings.ToUpper(code)] = url
+	}
+	if len(e.Languages) > 0 {
+		languages, err := newLanguageTargets(e.Languages)
+		if err != nil {
+			return r, fmt.Errorf("languages for %s: %s", e.Path, err)
+		}
+		r.languages = languages
+	}
+	var err error
+	if r.activeFrom, err = parseEntryTime(e.Path, "active_from", e.ActiveFrom); err != nil {
+		return r, err
+	}
+	if r.activeUntil, err = parseEntryTime(e.Path, "active_until", e.ActiveUntil); err != nil {
+		return r, err
+	}
+	expires, err := parseEntryTime(e.Path, "expires", e.Expires)
+	if err != nil {
+		return r, err
+	}
+	if !expires.IsZero() && (r.activeUntil.IsZero() || expires.Before(r.activeUntil)) {
+		r.activeUntil = expires
+	}
+	return r, nil
+}
+
+// parseEntryTime parses the optional RFC3339 timestamp value of
+// the named field of the entry for path.
+func parseEntryTime(path, field, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return t, fmt.Errorf("%s for %s: %s", field, path, err)
+	}
+	return t, nil
+}
+
+// active reports whether r may be served at time now.
+func (r redirect) active(now time.Time) bool {
+	if !r.activeFrom.IsZero() && now.Before(r.activeFrom) {
+		return false
+	}
+	return r.activeUntil.IsZero() || now.Before(r.activeUntil)
+}
+
+// requestTarget returns the target r overrides its url with for
+// req, if any.
+func (r redirect) requestTarget(req *http.Request, geo geoLookup) (string, bool) {
+	if len(r.countries) > 0 {
+		if target, ok := geo.target(req, r.countries); ok {
+			return target, true
+		}
+	}
+	if target, ok := r.devices[DeviceOf(req)]; ok {
+		return target, true
+	}
+	if r.languages != nil {
+		return r.languages.match(req.Header.Get("Accept-Language"))
+	}
+	return "", false
+}
+
+// pick chooses one of the targets of r at random, in proportion
+// to their weights.
+func (r redirect) pick(n int) string {
+	for _, t := range r.targets {
+		if n < t.Weight {
+			return t.URL
+		}
+		n -= t.Weight
+	}
+	return r.targets[len(r.targets)-1].URL
+}
+
+func (s *entryStore) Lookup(path string) (string, bool, error) {
+	url, ok, err := s.lookupRequest(nil, path)
+	if err == errInactive {
+		return "", false, nil
+	}
+	return url, ok, err
+}
+
+// lookupRequest resolves path for req, which picks between the
+// per-country, per-device and per-language targets of the entry,
+// in that order. A nil req gets the default target.
+func (s *entryStore) lookupRequest(req *http.Request, path string) (string, bool, error) {
+	r, ok := s.redirect(path)
+	if !ok {
+		return "", false, nil
+	}
+	if !r.active(s.now()) {
+		return "", false, errInactive
+	}
+	if r.disabled {
+		return "", false, &DisabledError{Path: path, Message: r.disabledMessage}
+	}
+	url := r.url
+	if req != nil {
+		if target, ok := r.requestTarget(req, s.geo); ok {
+			url = target
+		}
+	}
+	if url == "" && len(r.targets) > 0 {
+		s.mu.Lock()
+		n := s.rng.Intn(r.totalWeight)
+		s.mu.Unlock()
+		url = r.pick(n)
+	}
+	if len(r.utm) > 0 {
+		url = addParams(url, r.utm, s.overrideUTM)
+	}
+	return url, true, nil
+}
+
+func (s *entryStore) redirectResponse(path string) response {
+	r, _ := s.redirect(path)
+	return r.resp
+}
+
+// redirect returns the redirect for path, which is the one of the
+// exact path if there is one, or else the one of the first glob
+// matching path.
+func (s *entryStore) redirect(path string) (redirect, bool) {
+	if r, ok := s.redirects[path]; ok {
+		return r, true
+	}
+	for _, g := range s.globs {
+		if g.match(path) {
+			return s.redirects[g.path], true
+		}
+	}
+	return redirect{}, false
+}
+
+// StoreHandler will return an http.HandlerFunc that looks up
+// the request path in the provided Store and redirects to the
+// resulting URL. If the path is not found, or the Store returns
+// an error, the fallback http.Handler will be called instead.
+// Store errors are logged. Disabled paths are answered with 410
+// Gone; see DisabledError. Paths that are found but not
+// redirected reach the fallback with a Rejection in the request
+// context; see RejectionFromContext.
+func StoreHandler(store Store, fallback http.Handler) http.HandlerFunc {
+	handler, _ := StoreHandlerWithStatus(store, fallback, http.StatusFound)
+	return handler
+}
+
+// StoreHandlerWithStatus behaves like StoreHandler but redirects
+// using the provided status code. See MapHandlerWithStatus for
+// the accepted codes.
+func StoreHandlerWithStatus(store Store, fallback http.Handler, status int) (http.HandlerFunc, error) {
+	opts, err := statusOptions(status)
+	if err != nil {
+		return nil, err
+	}
+	return StoreHandlerWithOptions(store, fallback, opts)
+}
+
+// StoreHandlerWithOptions behaves like StoreHandler but responds
+// to matched paths as configured by opts. An error is returned
+// if opts is invalid.
+func StoreHandlerWithOptions(store Store, fallback http.Handler, opts Options) (http.HandlerFunc, error) {
+	opts, err := opts.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+	fallback = opts.missHandler(fallback)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		path, ok := opts.requestPath(r)
+		if !ok {
+			opts.miss(w, r, fallback)
+			return
+		}
+		// HEAD and preflight requests are not followed, so they
+		// must not use up single-use redirects, which they do not
+		// find, or count as hits.
+		consume := r.Method != http.MethodHead && r.Method != http.MethodOptions
+		url, resp, ok, err := opts.lookup(r, store, path, consume)
+		var disabled *DisabledError
+		switch {
+		case errors.As(err, &disabled):
+			if !opts.DisabledFallback {
+				gone(w, disabled)
+				return
+			}
+			r = withRejection(r, Rejection{Path: path, Reason: RejectedDisabled})
+		case err == errInactive:
+			r = withRejection(r, Rejection{Path: path, Reason: RejectedInactive})
+		case err != nil:
+			log.Printf("urlshort: lookup %s: %v", path, err)
+			if opts.GatewayTimeout && r.Context().Err() != nil {
+				http.Error(w, "lookup timed out", http.StatusGatewayTimeout)
+				return
+			}
+		case ok && !opts.allowed(url):
+			r = withRejection(r, Rejection{Path: path, Target: url, Reason: RejectedNotAllowed})
+		}
+		if ok && err == nil && opts.allowed(url) {
+			if opts.BlockLoops && opts.redirectsAgain(r, store, url) {
+				log.Printf("urlshort: not redirecting %s to %s: target would redirect again", path, url)
+				http.Error(w, "redirect loop detected", http.StatusLoopDetected)
+				return
+			}
+			if opts.CORS != nil && isPreflight(r) {
+				opts.CORS.preflight(w, r)
+				return
+			}
+			opts.redirect(w, r, resp, path, url)
+		} else {
+			opts.miss(w, r, fallback)
+		}
+	}, nil
+}
@@ -0,0 +1,189 @@
+package urlshort
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockStore is a ContextStore whose lookups never finish before the
+// request context is done.
+type blockStore struct{}
+
+func (blockStore) Lookup(string) (string, bool, error) { select {} }
+
+func (blockStore) LookupContext(ctx context.Context, _ string) (string, bool, error) {
+	<-ctx.Done()
+	return "", false, ctx.Err()
+}
+
+func TestStoreHandler(t *testing.T) {
+	h := StoreHandler(MapStore{"/a": "https://a.com"}, notFound)
+	expectRedirect(t, h, "/a", http.StatusFound, "https://a.com")
+	expectStatus(t, h, "/b", http.StatusNotFound)
+
+	expectStatus(t, StoreHandler(errStore{}, notFound), "/a", http.StatusNotFound)
+}
+
+func TestStoreHandlerContext(t *testing.T) {
+	run := func(h http.Handler) int {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/a", nil).WithContext(ctx))
+		return w.Code
+	}
+	h, _ := StoreHandlerWithOptions(blockStore{}, notFound, Options{})
+	if code := run(h); code != http.StatusNotFound {
+		t.Errorf("cancelled lookup = %d, want 404", code)
+	}
+	h, _ = StoreHandlerWithOptions(blockStore{}, notFound, Options{GatewayTimeout: true})
+	if code := run(h); code != http.StatusGatewayTimeout {
+		t.Errorf("cancelled lookup with GatewayTimeout = %d, want 504", code)
+	}
+}
+
+func TestMapStoreEach(t *testing.T) {
+	var paths []string
+	MapStore{"/z": "https://z.com", "/m": "https://m.com"}.Each(func(path, url string) error {
+		paths = append(paths, path)
+		return nil
+	})
+	if got := strings.Join(paths, ","); got != "/m,/z" {
+		t.Errorf("Each visited %s, want /m,/z", got)
+	}
+}
+
+func TestMutableMapStore(t *testing.T) {
+	var m MutableMapStore
+	h := StoreHandler(&m, notFound)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				path := fmt.Sprintf("/%d/%d", i, j)
+				m.Put(path, "https://x.com")
+				if j%2 == 0 {
+					m.Delete(path)
+				}
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				serve(h, http.MethodGet, "/0/1")
+			}
+		}()
+	}
+	wg.Wait()
+
+	n := 0
+	m.Each(func(string, string) error {
+		n++
+		return nil
+	})
+	if n != 800 {
+		t.Errorf("Each visited %d entries, want 800", n)
+	}
+	expectStatus(t, h, "/3/5", http.StatusFound)
+	expectStatus(t, h, "/3/4", http.StatusNotFound)
+
+	var _ WritableStore = &m
+	var _ ListableStore = NewMutableMapStore(nil)
+}
+
+func TestDisabledEntries(t *testing.T) {
+	doc := "- path: /a\n  url: https://a.com\n  disabled: true\n  disabled_message: paused\n" +
+		"- path: /b\n  url: https://b.com\n  disabled: false\n" +
+		"- path: /c\n  url: https://c.com\n  disabled: true\n"
+	h, err := YAMLHandler([]byte(doc), notFound)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := serve(h, http.MethodGet, "/a"); w.Code != http.StatusGone || !strings.Contains(w.Body.String(), "paused") {
+		t.Errorf("GET /a = %d %q, want 410 with the custom message", w.Code, w.Body)
+	}
+	if w := serve(h, http.MethodGet, "/c"); w.Code != http.StatusGone || !strings.Contains(w.Body.String(), "disabled") {
+		t.Errorf("GET /c = %d %q, want 410 with the default message", w.Code, w.Body)
+	}
+	expectStatus(t, h, "/b", http.StatusFound)
+	expectStatus(t, h, "/x", http.StatusNotFound)
+}
+
+func TestExpiringEntries(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	doc := "- path: /old\n  url: https://o.com\n  expires: 2019-01-01T00:00:00Z\n" +
+		"- path: /new\n  url: https://n.com\n  expires: 2021-01-01T00:00:00Z\n" +
+		"- path: /ever\n  url: https://e.com\n"
+	h, err := YAMLHandlerWithOptions([]byte(doc), notFound, Options{Clock: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, h, "/old", http.StatusNotFound)
+	expectStatus(t, h, "/new", http.StatusFound)
+	expectStatus(t, h, "/ever", http.StatusFound)
+
+	if _, err := JSONHandler([]byte(`[{"path": "/x", "url": "https://x", "expires": "soon"}]`), notFound); err == nil {
+		t.Error("expected an error for an unparsable expiry")
+	}
+
+	c, err := CSVHandlerWithOptions([]byte("path,url,expires\n/old,https://o.com,2019-01-01T00:00:00Z\n"), notFound, Options{Clock: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, c, "/old", http.StatusNotFound)
+}
+
+func TestActiveWindow(t *testing.T) {
+	clock := NewFakeClock(time.Time{})
+	doc := `[{"path": "/l", "url": "https://l.com", "active_from": "2020-01-01T00:00:00Z", "active_until": "2020-02-01T00:00:00Z"}]`
+	h, err := JSONHandlerWithOptions([]byte(doc), notFound, Options{Clock: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		at     string
+		status int
+	}{
+		{"2019-12-31T00:00:00Z", http.StatusNotFound},
+		{"2020-01-15T00:00:00Z", http.StatusFound},
+		{"2020-02-01T00:00:00Z", http.StatusNotFound},
+	} {
+		at, _ := time.Parse(time.RFC3339, tc.at)
+		clock.Set(at)
+		if w := serve(h, http.MethodGet, "/l"); w.Code != tc.status {
+			t.Errorf("at %s: GET /l = %d, want %d", tc.at, w.Code, tc.status)
+		}
+	}
+}
+
+func TestWeightedTargets(t *testing.T) {
+	doc := "- path: /s\n  targets:\n    - url: https://a.com\n      weight: 70\n    - url: https://b.com\n      weight: 30\n" +
+		"- path: /p\n  url: https://p.com\n"
+	h, err := YAMLHandlerWithOptions([]byte(doc), notFound, Options{Rand: rand.New(rand.NewSource(1))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := 0
+	for i := 0; i < 5000; i++ {
+		if serve(h, http.MethodGet, "/s").Header().Get("Location") == "https://a.com" {
+			a++
+		}
+	}
+	if a < 3300 || a > 3700 {
+		t.Errorf("https://a.com chosen %d of 5000 times, want about 3500", a)
+	}
+	expectRedirect(t, h, "/p", http.StatusFound, "https://p.com")
+
+	if _, err := YAMLHandler([]byte("- path: /s\n  targets:\n    - url: https://a.com\n      weight: 0\n"), notFound); err == nil {
+		t.Error("expected an error for a zero weight")
+	}
+}
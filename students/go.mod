@@ -0,0 +1,5 @@
+// The solutions in this directory are GOPATH-era programs kept for
+// reference. This file keeps them out of the urlshort module.
+module github.com/gophercises/urlshort/students
+
+go 1.21
@@ -0,0 +1,72 @@
+package urlshort
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// SuggestHandler will return an http.HandlerFunc meant to be used
+// as the NotFound option of a handler built from pathsToUrls. It
+// looks for the known path closest to the request path by edit
+// distance and, if one is within maxDistance edits, either
+// suggests it in a 404 response or, when redirect is true,
+// redirects to it. Otherwise it responds with a plain 404.
+func SuggestHandler(pathsToUrls map[string]string, maxDistance int, redirect bool) http.HandlerFunc {
+	paths := make([]string, 0, len(pathsToUrls))
+	for path := range pathsToUrls {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		suggestion, ok := closestPath(paths, r.URL.Path, maxDistance)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if redirect {
+			http.Redirect(w, r, suggestion, http.StatusFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, "404 page not found\nDid you mean %s?\n", suggestion)
+	}
+}
+
+// closestPath returns the path in the sorted paths with the
+// smallest edit distance to target, provided it is at most
+// maxDistance. Ties go to the path that sorts first.
+func closestPath(paths []string, target string, maxDistance int) (string, bool) {
+	best, bestDistance := "", maxDistance+1
+	for _, path := range paths {
+		if d := levenshtein(path, target); d < bestDistance {
+			best, bestDistance = path, d
+		}
+	}
+	return best, bestDistance <= maxDistance
+}
+
+// levenshtein returns the number of single rune insertions,
+// deletions and substitutions needed to turn a into b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
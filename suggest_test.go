@@ -0,0 +1,26 @@
+package urlshort
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSuggestHandler(t *testing.T) {
+	m := map[string]string{"/docs": "https://d.com"}
+	h, err := MapHandlerWithOptions(m, nil, Options{NotFound: SuggestHandler(m, 2, false)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := serve(h, http.MethodGet, "/docss")
+	if w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), "Did you mean /docs?") {
+		t.Errorf("GET /docss = %d %q, want a suggestion", w.Code, w.Body)
+	}
+	if w := serve(h, http.MethodGet, "/zzzzzzz"); strings.Contains(w.Body.String(), "Did you") {
+		t.Errorf("GET /zzzzzzz suggested %q", w.Body)
+	}
+	expectStatus(t, h, "/docs", http.StatusFound)
+
+	h, _ = MapHandlerWithOptions(m, nil, Options{NotFound: SuggestHandler(m, 2, true)})
+	expectRedirect(t, h, "/doc", http.StatusFound, "/docs")
+}
@@ -0,0 +1,139 @@
+package urlshort
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// templateVar matches a {name} placeholder.
+var templateVar = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// TemplateStore is a Store that resolves paths against patterns
+// with named segments, such as /u/{user}, and substitutes the
+// segments of the request path into the target, so with the
+// target https://example.com/profiles/{user} the path /u/alice
+// redirects to https://example.com/profiles/alice. A named
+// segment matches one whole, non-empty path segment.
+//
+// When several patterns match a path, the one with a literal
+// segment where the others have a named one wins, comparing
+// segments from the left, so /u/me takes precedence over
+// /u/{user}.
+type TemplateStore struct {
+	routes []templateRoute
+}
+
+type templateRoute struct {
+	pattern  string
+	segments []string // literal segments, or "" for named ones
+	names    []string // names of the named segments, by position
+	target   string
+}
+
+// NewTemplateStore builds a TemplateStore from a mapping of
+// patterns to target templates. An error is returned if a pattern
+// is malformed, names a segment twice, or if a target uses a name
+// its pattern does not define.
+func NewTemplateStore(patternsToUrls map[string]string) (*TemplateStore, error) {
+	s := &TemplateStore{}
+	for pattern, target := range patternsToUrls {
+		route, err := parseTemplateRoute(pattern, target)
+		if err != nil {
+			return nil, err
+		}
+		s.routes = append(s.routes, route)
+	}
+	sort.Slice(s.routes, func(i, j int) bool {
+		return s.routes[i].before(s.routes[j])
+	})
+	return s, nil
+}
+
+// parseTemplateRoute parses pattern and checks that target only
+// uses the names it defines.
+func parseTemplateRoute(pattern, target string) (templateRoute, error) {
+	r := templateRoute{pattern: pattern, target: target}
+	defined := make(map[string]bool)
+	for _, segment := range strings.Split(pattern, "/") {
+		name := ""
+		if m := templateVar.FindStringSubmatch(segment); m != nil && m[0] == segment {
+			name = m[1]
+			if defined[name] {
+				return r, fmt.Errorf("pattern %s names {%s} twice", pattern, name)
+			}
+			defined[name] = true
+			segment = ""
+		} else if strings.ContainsAny(segment, "{}") {
+			return r, fmt.Errorf("pattern %s: segment %q must be a literal or a single {name}", pattern, segment)
+		}
+		r.segments = append(r.segments, segment)
+		r.names = append(r.names, name)
+	}
+	for _, m := range templateVar.FindAllStringSubmatch(target, -1) {
+		if !defined[m[1]] {
+			return r, fmt.Errorf("target %s for %s uses undefined {%s}", target, pattern, m[1])
+		}
+	}
+	return r, nil
+}
+
+// before reports whether r is more specific than other.
+func (r templateRoute) before(other templateRoute) bool {
+	for i := 0; i < len(r.segments) && i < len(other.segments); i++ {
+		literal, otherLiteral := r.names[i] == "", other.names[i] == ""
+		if literal != otherLiteral {
+			return literal
+		}
+	}
+	return r.pattern < other.pattern
+}
+
+// match returns the target of r for path, with the named segments
+// substituted, and reports whether path matches r.
+func (r templateRoute) match(path string) (string, bool) {
+	segments := strings.Split(path, "/")
+	if len(segments) != len(r.segments) {
+		return "", false
+	}
+	values := make(map[string]string)
+	for i, segment := range segments {
+		if name := r.names[i]; name != "" {
+			if segment == "" {
+				return "", false
+			}
+			values[name] = url.PathEscape(segment)
+		} else if segment != r.segments[i] {
+			return "", false
+		}
+	}
+	return templateVar.ReplaceAllStringFunc(r.target, func(v string) string {
+		return values[v[1:len(v)-1]]
+	}), true
+}
+
+// Lookup returns the target of the most specific pattern matching
+// path, with the named segments of path substituted.
+func (s *TemplateStore) Lookup(path string) (string, bool, error) {
+	for _, route := range s.routes {
+		if target, ok := route.match(path); ok {
+			return target, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// TemplateHandler behaves like MapHandler but accepts patterns with named segments such as
+// /u/{user}, whose values are substituted into the target. Else falls back to provided Handler.
+// Malformed patterns and targets using undefined names are reported here rather than when
+// serving requests. See TemplateStore.
+func TemplateHandler(patternsToUrls map[string]string, fallback http.Handler) (http.HandlerFunc, error) {
+	store, err := NewTemplateStore(patternsToUrls)
+	if err != nil {
+		return nil, err
+	}
+	return StoreHandler(store, fallback), nil
+}
@@ -0,0 +1,44 @@
+package urlshort
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestTemplateHandler(t *testing.T) {
+	h, err := TemplateHandler(map[string]string{
+		"/u/{user}":         "https://example.com/profiles/{user}",
+		"/u/me":             "https://example.com/me",
+		"/r/{owner}/{repo}": "https://github.com/{owner}/{repo}/issues?q={repo}",
+		"/r/golang/{repo}":  "https://go.dev/{repo}",
+		"/static":           "https://s.com",
+	}, notFound)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]string{
+		"/u/alice":     "https://example.com/profiles/alice",
+		"/u/me":        "https://example.com/me",
+		"/r/a/b":       "https://github.com/a/b/issues?q=b",
+		"/r/golang/go": "https://go.dev/go",
+		"/u/a%20b":     "https://example.com/profiles/a%20b",
+		"/static":      "https://s.com",
+	} {
+		expectRedirect(t, h, path, http.StatusFound, want)
+	}
+	for _, path := range []string{"/u/", "/u/a/b"} {
+		expectStatus(t, h, path, http.StatusNotFound)
+	}
+}
+
+func TestTemplateHandlerErrors(t *testing.T) {
+	for _, bad := range []map[string]string{
+		{"/u/{user}": "https://x.com/{name}"},
+		{"/u/{a}/{a}": "https://x.com"},
+		{"/u/x{a}": "https://x.com"},
+	} {
+		if _, err := TemplateHandler(bad, notFound); err == nil {
+			t.Errorf("TemplateHandler(%v): expected an error", bad)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package urlshort
+
+import (
+	"context"
+	"strings"
+)
+
+// TenantStore is a Store that serves the redirects of several
+// tenants from one BoltDB file. Request paths of the form
+// prefix + "{tenant}/{code}" are looked up as /{code} in the
+// tenant's bucket; see BoltStore.Tenant. Paths without a tenant,
+// with an invalid tenant name, or whose tenant has no bucket, are
+// not found.
+type TenantStore struct {
+	store  *BoltStore
+	prefix string
+}
+
+// NewTenantStore returns a TenantStore for the tenants of store
+// whose paths start with prefix, such as "/t/".
+func NewTenantStore(store *BoltStore, prefix string) *TenantStore {
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return &TenantStore{store: store, prefix: prefix}
+}
+
+// Lookup returns the url stored for the code of path in the
+// bucket of its tenant.
+func (s *TenantStore) Lookup(path string) (string, bool, error) {
+	tenant, code, ok := s.split(path)
+	if !ok {
+		return "", false, nil
+	}
+	return tenant.Lookup(code)
+}
+
+// Peek behaves like Lookup but uses BoltStore.Peek.
+func (s *TenantStore) Peek(path string) (string, bool, error) {
+	tenant, code, ok := s.split(path)
+	if !ok {
+		return "", false, nil
+	}
+	return tenant.Peek(code)
+}
+
+// Reveal behaves like Peek but uses BoltStore.Reveal.
+func (s *TenantStore) Reveal(path string) (string, bool, error) {
+	tenant, code, ok := s.split(path)
+	if !ok {
+		return "", false, nil
+	}
+	return tenant.Reveal(code)
+}
+
+// Ping checks the underlying BoltStore.
+func (s *TenantStore) Ping(ctx context.Context) error {
+	return s.store.Ping(ctx)
+}
+
+// split splits path into the store of its tenant and the path of
+// the code within the tenant. Invalid tenant names are not found.
+func (s *TenantStore) split(path string) (tenant *BoltStore, code string, ok bool) {
+	if !strings.HasPrefix(path, s.prefix) {
+		return nil, "", false
+	}
+	rest := strings.TrimPrefix(path, s.prefix)
+	i := strings.Index(rest, "/")
+	if i <= 0 || i == len(rest)-1 {
+		return nil, "", false
+	}
+	tenant, err := s.store.Tenant(rest[:i])
+	if err != nil {
+		return nil, "", false
+	}
+	return tenant, rest[i:], true
+}
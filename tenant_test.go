@@ -0,0 +1,47 @@
+package urlshort
+
+import (
+	"net/http"
+	"testing"
+)
+
+// mustTenant returns the store of the named tenant of s.
+func mustTenant(t *testing.T, s *BoltStore, name string) *BoltStore {
+	t.Helper()
+	ts, err := s.Tenant(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ts
+}
+
+func TestTenantStore(t *testing.T) {
+	s := openTestBolt(t, BoltOptions{})
+	mustTenant(t, s, "acme").Put("/go", "https://acme.com")
+	mustTenant(t, s, "umbrella").Put("/go", "https://umbrella.com")
+	s.Put("/go", "https://root.com")
+
+	h := StoreHandler(NewTenantStore(s, "/t"), notFound)
+	expectRedirect(t, h, "/t/acme/go", http.StatusFound, "https://acme.com")
+	expectRedirect(t, h, "/t/umbrella/go", http.StatusFound, "https://umbrella.com")
+	for _, path := range []string{"/t/nobody/go", "/go", "/t/acme", "/t/acme/", "/t//go", "/t/acme/nope"} {
+		expectStatus(t, h, path, http.StatusNotFound)
+	}
+}
+
+func TestTenantNames(t *testing.T) {
+	s := openTestBolt(t, BoltOptions{CountHits: true})
+	mustTenant(t, s, "acme").Put("/x", "https://x.com")
+	h := StoreHandler(NewTenantStore(s, "/t/"), notFound)
+	expectStatus(t, h, "/t/acme/x", http.StatusFound)
+
+	// The hit counters of acme live in a bucket named after it, which
+	// must not be reachable as a tenant of its own.
+	expectStatus(t, h, "/t/acme.hits/x", http.StatusNotFound)
+
+	for _, name := range []string{"", "a.b", "a/b", "a\x00"} {
+		if _, err := s.Tenant(name); err == nil {
+			t.Errorf("Tenant(%q): expected an error", name)
+		}
+	}
+}
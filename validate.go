@@ -0,0 +1,117 @@
+package urlshort
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DefaultReservedPaths are the routes of the operational handlers
+// in this package, which redirects should not shadow.
+var DefaultReservedPaths = []string{"/admin", "/health", "/healthz", "/metrics", "/preview", "/qr"}
+
+// ValidateOptions configures Validate.
+type ValidateOptions struct {
+	// ReservedPaths are paths that no entry may define, along
+	// with every path below them. As for Options, nil reserves
+	// nothing; set it to DefaultReservedPaths to check documents
+	// served next to the handlers of this package.
+	ReservedPaths []string
+}
+
+// Validate checks a mapping document in the given format without
+// building a handler, and returns every problem found: syntax
+// errors, duplicate paths, paths that do not start with a slash
+// or are reserved, invalid entry fields and targets that are not
+// absolute URLs. A valid document returns no errors.
+func Validate(data []byte, format Format, opts ValidateOptions) []error {
+	entries, err := decodeEntries(data, format)
+	if err != nil {
+		return []error{err}
+	}
+
+	var errs []error
+	if _, err := buildRedirectMapStrict(entries); err != nil {
+		errs = append(errs, err)
+	}
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Path, "/") {
+			errs = append(errs, fmt.Errorf("path %q does not start with /", e.Path))
+		}
+		if isReserved(e.Path, opts.ReservedPaths) {
+			errs = append(errs, fmt.Errorf("path %s is reserved", e.Path))
+		}
+		if _, err := parseEntry(e); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := checkTargets(entries); err != nil {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// isReserved reports whether path is one of reserved or below
+// one of them.
+func isReserved(path string, reserved []string) bool {
+	for _, r := range reserved {
+		r = strings.TrimSuffix(r, "/")
+		if path == r || strings.HasPrefix(path, r+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Conflict is a path defined by more than one source passed to
+// DetectConflicts.
+type Conflict struct {
+	Path string
+
+	// Targets maps the name of each source defining Path to the
+	// target it gives.
+	Targets map[string]string
+
+	// Disagree reports whether the sources give Path different
+	// targets. Paths defined identically by several sources are
+	// harmless but redundant.
+	Disagree bool
+}
+
+// DetectConflicts returns every path defined by more than one of
+// sources, which maps source names, such as file names, to their
+// mappings of paths to urls. Conflicts are returned in path order.
+// Handlers combining the sources, such as a MultiHandler, would
+// serve only one of the targets of such paths.
+func DetectConflicts(sources map[string]map[string]string) []Conflict {
+	defined := make(map[string]map[string]string)
+	for name, paths := range sources {
+		for path, url := range paths {
+			if defined[path] == nil {
+				defined[path] = make(map[string]string)
+			}
+			defined[path][name] = url
+		}
+	}
+
+	var conflicts []Conflict
+	for path, targets := range defined {
+		if len(targets) < 2 {
+			continue
+		}
+		c := Conflict{Path: path, Targets: targets}
+		var first string
+		for _, url := range targets {
+			if first == "" {
+				first = url
+			} else if url != first {
+				c.Disagree = true
+			}
+		}
+		conflicts = append(conflicts, c)
+	}
+	sort.Slice(conflicts, func(i, j int) bool {
+		return conflicts[i].Path < conflicts[j].Path
+	})
+	return conflicts
+}
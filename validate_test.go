@@ -0,0 +1,63 @@
+package urlshort
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	if errs := Validate([]byte("/a: https://a.com\n"), FormatYAML, ValidateOptions{}); len(errs) != 0 {
+		t.Errorf("valid document: %v", errs)
+	}
+	if errs := Validate([]byte("{"), FormatJSON, ValidateOptions{}); len(errs) != 1 {
+		t.Errorf("syntax error reported as %v, want one error", errs)
+	}
+	if errs := Validate([]byte("[[redirects]]\npath = \"/a\"\nurl = \"relative\"\n"), FormatTOML, ValidateOptions{}); len(errs) != 1 {
+		t.Errorf("TOML document reported as %v, want one error", errs)
+	}
+
+	doc := []byte("- {path: /a, url: https://a.com}\n" +
+		"- {path: /a, url: https://b.com}\n" +
+		"- {path: nope, url: https://c.com}\n" +
+		"- {path: /admin/x, url: https://d.com}\n" +
+		"- {path: /e, url: relative}\n" +
+		"- {path: /f, url: https://f.com, expires: soon}\n")
+	if errs := Validate(doc, FormatYAML, ValidateOptions{}); len(errs) != 4 {
+		t.Errorf("got %d errors, want 4: %v", len(errs), errs)
+	}
+	if errs := Validate(doc, FormatYAML, ValidateOptions{ReservedPaths: DefaultReservedPaths}); len(errs) != 5 {
+		t.Errorf("with the default reserved paths, got %d errors, want 5: %v", len(errs), errs)
+	}
+}
+
+func TestValidateMatchesHandler(t *testing.T) {
+	doc := []byte("- {path: /admin/x, url: https://a.com}\n")
+	for _, reserved := range [][]string{nil, DefaultReservedPaths} {
+		errs := Validate(doc, FormatYAML, ValidateOptions{ReservedPaths: reserved})
+		h, err := YAMLHandlerWithOptions(doc, notFound, Options{ReservedPaths: reserved})
+		if err != nil {
+			t.Fatal(err)
+		}
+		served := serve(h, http.MethodGet, "/admin/x").Code == http.StatusFound
+		if served != (len(errs) == 0) {
+			t.Errorf("reserved %v: Validate reported %v but the handler served the path: %v", reserved, errs, served)
+		}
+	}
+}
+
+func TestDetectConflicts(t *testing.T) {
+	got := DetectConflicts(map[string]map[string]string{
+		"a.yaml": {"/x": "https://x.com", "/y": "https://y.com", "/only": "https://o.com"},
+		"b.json": {"/x": "https://x.com", "/y": "https://other.com"},
+		"bolt":   {"/y": "https://y.com"},
+	})
+	if len(got) != 2 {
+		t.Fatalf("DetectConflicts = %+v, want /x and /y", got)
+	}
+	if got[0].Path != "/x" || got[0].Disagree || len(got[0].Targets) != 2 {
+		t.Errorf("conflict for /x = %+v, want two agreeing sources", got[0])
+	}
+	if got[1].Path != "/y" || !got[1].Disagree || len(got[1].Targets) != 3 || got[1].Targets["b.json"] != "https://other.com" {
+		t.Errorf("conflict for /y = %+v, want three disagreeing sources", got[1])
+	}
+}
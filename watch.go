@@ -0,0 +1,170 @@
+package urlshort
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce is how long a watched file must go without
+// changes before it is reloaded.
+const watchDebounce = 100 * time.Millisecond
+
+// swapStore is a Store built from the entries of a mapping
+// document that can be replaced atomically while lookups are
+// being served.
+type swapStore struct {
+	mu    sync.RWMutex
+	store *entryStore
+}
+
+func (s *swapStore) current() *entryStore {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.store
+}
+
+func (s *swapStore) Lookup(path string) (string, bool, error) {
+	return s.current().Lookup(path)
+}
+
+func (s *swapStore) lookupRequest(r *http.Request, path string) (string, bool, error) {
+	return s.current().lookupRequest(r, path)
+}
+
+func (s *swapStore) redirectResponse(path string) response {
+	return s.current().redirectResponse(path)
+}
+
+// swap replaces the served entries with those of store.
+func (s *swapStore) swap(store *entryStore) {
+	s.mu.Lock()
+	s.store = store
+	s.mu.Unlock()
+}
+
+// parseEntryStore decodes a mapping document in the given format
+// into the same store YAMLHandlerWithOptions and
+// JSONHandlerWithOptions serve with opts.
+func (opts Options) parseEntryStore(data []byte, format Format) (*entryStore, error) {
+	entries, err := decodeEntriesStrict(data, format, opts.StrictFields)
+	if err != nil {
+		return nil, err
+	}
+	return opts.entryStore(entries)
+}
+
+// swapHandler serves store as entriesHandler serves the entries
+// of a document.
+func (opts Options) swapHandler(store *swapStore, fallback http.Handler) (http.HandlerFunc, error) {
+	opts.UTM = nil
+	return StoreHandlerWithOptions(store, fallback, opts)
+}
+
+// WatchYAMLHandler behaves like YAMLHandler but reads the YAML from the file at path
+// and reloads it whenever the file changes. A reload that fails to parse is logged
+// and the last good mapping keeps being served. The returned function stops watching.
+func WatchYAMLHandler(path string, fallback http.Handler) (http.HandlerFunc, func() error, error) {
+	return WatchYAMLHandlerWithOptions(path, fallback, Options{})
+}
+
+// WatchYAMLHandlerWithOptions behaves like WatchYAMLHandler but parses and serves
+// the mapping as configured by opts, on every reload.
+func WatchYAMLHandlerWithOptions(path string, fallback http.Handler, opts Options) (http.HandlerFunc, func() error, error) {
+	return watchHandler(path, FormatYAML, fallback, opts)
+}
+
+// WatchJSONHandler behaves like WatchYAMLHandler but for a JSON file.
+func WatchJSONHandler(path string, fallback http.Handler) (http.HandlerFunc, func() error, error) {
+	return WatchJSONHandlerWithOptions(path, fallback, Options{})
+}
+
+// WatchJSONHandlerWithOptions behaves like WatchYAMLHandlerWithOptions but for a
+// JSON file.
+func WatchJSONHandlerWithOptions(path string, fallback http.Handler, opts Options) (http.HandlerFunc, func() error, error) {
+	return watchHandler(path, FormatJSON, fallback, opts)
+}
+
+func watchHandler(path string, format Format, fallback http.Handler, opts Options) (http.HandlerFunc, func() error, error) {
+	load := opts.fileLoader(path, format)
+	entries, err := load()
+	if err != nil {
+		return nil, nil, err
+	}
+	store := &swapStore{store: entries}
+	h, err := opts.swapHandler(store, fallback)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, nil, err
+	}
+	// Watch the directory rather than the file itself so that
+	// editors which save by renaming a new file into place keep
+	// being noticed.
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return nil, nil, err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var reload <-chan time.Time
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != filepath.Clean(path) ||
+					!event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) {
+					continue
+				}
+				// Saving a file usually produces several events,
+				// the first of which may see it truncated. Wait
+				// for them to settle before reading it.
+				reload = time.After(watchDebounce)
+			case <-reload:
+				reload = nil
+				entries, err := load()
+				if err != nil {
+					log.Printf("urlshort: reload %s: %v", path, err)
+					continue
+				}
+				store.swap(entries)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("urlshort: watch %s: %v", path, err)
+			}
+		}
+	}()
+
+	stop := func() error {
+		err := watcher.Close()
+		<-done
+		return err
+	}
+	return h, stop, nil
+}
+
+// fileLoader returns a function that reads the file at path and
+// parses it in the given format as configured by opts.
+func (opts Options) fileLoader(path string, format Format) func() (*entryStore, error) {
+	return func() (*entryStore, error) {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return opts.parseEntryStore(data, format)
+	}
+}
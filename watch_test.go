@@ -0,0 +1,99 @@
+package urlshort
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeFile writes data to name, failing the test on error.
+func writeFile(t *testing.T, name, data string) {
+	t.Helper()
+	if err := os.WriteFile(name, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// eventually retries check until it returns true or a few seconds
+// have passed.
+func eventually(check func() bool) bool {
+	deadline := time.Now().Add(5 * time.Second)
+	for !check() {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return true
+}
+
+func TestWatchYAMLHandler(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "m.yaml")
+	writeFile(t, f, "/a: https://a.com\n")
+	h, stop, err := WatchYAMLHandler(f, notFound)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	expectStatus(t, h, "/a", http.StatusFound)
+
+	writeFile(t, f, "/b: https://b.com\n")
+	if !eventually(func() bool { return serve(h, http.MethodGet, "/b").Code == http.StatusFound }) {
+		t.Fatal("change to the file was not picked up")
+	}
+
+	// A broken file keeps the last good mappings.
+	writeFile(t, f, "[[[bad")
+	time.Sleep(300 * time.Millisecond)
+	expectStatus(t, h, "/b", http.StatusFound)
+}
+
+func TestWatchJSONHandlerEntries(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "m.json")
+	writeFile(t, f, `[{"path": "/a", "url": "https://a.com", "status": 301}]`)
+	h, stop, err := WatchJSONHandler(f, notFound)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	expectRedirect(t, h, "/a", http.StatusMovedPermanently, "https://a.com")
+
+	writeFile(t, f, `[{"path": "/a", "url": "https://a.com", "disabled": true}]`)
+	if !eventually(func() bool { return serve(h, http.MethodGet, "/a").Code == http.StatusGone }) {
+		t.Error("disabled entry was not picked up")
+	}
+}
+
+func TestWatchHandlerMissingFile(t *testing.T) {
+	if _, _, err := WatchYAMLHandler(filepath.Join(t.TempDir(), "missing.yaml"), notFound); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestWatchHandlerOptions(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "m.yaml")
+	writeFile(t, f, "/a: https://a.com\n")
+	h, stop, err := WatchYAMLHandlerWithOptions(f, notFound, Options{Status: http.StatusMovedPermanently, StrictFields: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	expectRedirect(t, h, "/a", http.StatusMovedPermanently, "https://a.com")
+
+	// Reloads are parsed with the same options, so a document
+	// with an unknown field is rejected.
+	writeFile(t, f, "- path: /a\n  urls: https://x.com\n")
+	time.Sleep(300 * time.Millisecond)
+	expectRedirect(t, h, "/a", http.StatusMovedPermanently, "https://a.com")
+
+	writeFile(t, f, "/b: https://b.com\n")
+	if !eventually(func() bool { return serve(h, http.MethodGet, "/b").Code == http.StatusMovedPermanently }) {
+		t.Fatal("change to the file was not picked up")
+	}
+
+	if _, _, err := WatchJSONHandlerWithOptions(f, notFound, Options{Status: http.StatusOK}); err == nil {
+		t.Error("expected an error for an invalid status")
+	}
+}